@@ -1,12 +1,18 @@
 package agents
+import "context"
 import "oscarkilo.com/inteluni/substrates"
 
 // Agent is an interface for an autonomous entity moving in the world.
 //
 // Each agent has a position, unique ID, and foresight depth.
 // Agents choose a Move based on the current state of the grid.
-// They know the entire substrate (grid) state at time of decision.
-// They can simulate the universe with a provided Evolver.
+// They are handed what they perceive of the substrate (grid) at time
+// of decision: the entire state by default, but perception may limit
+// them to a window around their position, with the cells beyond it
+// unknown (see Grid2d.Forget), and may misread cells.  The agent's own
+// cell and its neighbours are always seen.
+// They can simulate the universe with a provided Evolver, which
+// expects a fully known grid.
 //
 // They do NOT know:
 //   - The future moves of other agents.
@@ -32,6 +38,7 @@ type baseAgent struct {
   pos       substrates.Pos
   foresight int
   rng       *substrates.SplitMix64
+  calls     int  // evolver calls over the agent's life
 }
 
 func (a *baseAgent) ID() int {
@@ -46,12 +53,53 @@ func (a *baseAgent) Foresight() int {
   return a.foresight
 }
 
-// Apply executes the move and wraps around the grid (toroidal world).
+// EvolverCalls is how many times the agent has simulated a step of the
+// world, with the provided Evolver or a model of its own.
+func (a *baseAgent) EvolverCalls() int {
+  return a.calls
+}
+
+// counted wraps evolve to count its calls in EvolverCalls.
+func (a *baseAgent) counted(evolve substrates.Evolver) substrates.Evolver {
+  return func(
+      g *substrates.Grid2d,
+      rng *substrates.SplitMix64,
+  ) *substrates.Grid2d {
+    a.calls++
+    return evolve(g, rng)
+  }
+}
+
+// Apply executes the move across the edges of the grid's topology.
 func (a *baseAgent) Apply(m substrates.Move, g *substrates.Grid2d) {
-  w := g.W()
-  h := g.H()
-  a.pos.X = (a.pos.X + m.DX() + w) % w
-  a.pos.Y = (a.pos.Y + m.DY() + h) % h
+  a.pos = g.Step(a.pos, m)
+}
+
+// fillUnknown returns g with its unknown cells sampled, each on its
+// own, from the states of the known cells: a guess that what is out of
+// sight looks like what is in sight.  A fully known g is returned as
+// is, without drawing from rng.
+func fillUnknown(g *substrates.Grid2d, rng *substrates.SplitMix64) *substrates.Grid2d {
+  if !g.Partial() {
+    return g
+  }
+  var seen []int
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      if g.KnownXY(x, y) {
+        seen = append(seen, g.XY(x, y))
+      }
+    }
+  }
+  full := g.Clone()
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      if !g.KnownXY(x, y) {
+        full.SetXY(x, y, seen[rng.Intn(len(seen))])
+      }
+    }
+  }
+  return full
 }
 
 // ReactiveAgent is a depth‑0 agent that only sees the present.
@@ -79,8 +127,8 @@ func NewReactiveAgent(
 // Decide chooses one of the 5 reachable actions (N/E/S/W/Stay).
 //
 // Decision strategy:
-//   - Evaluate all adjacent cells (wraparound grid).
-//   - Prefer empty squares (value == 0).
+//   - Evaluate all adjacent cells (across edges per grid topology).
+//   - Prefer squares that are not lethal (see substrates.Lethal).
 //   - Break ties randomly.
 //   - Avoid obvious collisions if possible.
 func (a *ReactiveAgent) Decide(
@@ -88,6 +136,12 @@ func (a *ReactiveAgent) Decide(
     _ substrates.Evolver,
     _ bool,
   ) substrates.Move {
+  return a.reactiveMove(g)
+}
+
+// reactiveMove is the decision of ReactiveAgent, which planners fall
+// back on when they run out of budget before finding any move.
+func (a *baseAgent) reactiveMove(g *substrates.Grid2d) substrates.Move {
   moves := []substrates.Move{
     substrates.North,
     substrates.South,
@@ -96,19 +150,10 @@ func (a *ReactiveAgent) Decide(
     substrates.Stay,
   }
 
-  w := g.W()
-  h := g.H()
-  px := a.pos.X
-  py := a.pos.Y
-
   var open []substrates.Move
 
   for _, m := range moves {  // consider all of N/S/E/W/Stay
-    nx := (px + m.DX() + w) % w
-    ny := (py + m.DY() + h) % h
-
-    empty := g.XY(nx, ny) == 0
-    if empty {
+    if !substrates.Lethal(g.Get(g.Step(a.pos, m))) {
       open = append(open, m)
     }
   }
@@ -124,6 +169,42 @@ func (a *ReactiveAgent) Decide(
 // PredictiveAgent simulates future universe evolution before deciding.
 type PredictiveAgent struct {
   baseAgent
+  reducer SurvivalReducer  // nil means worst case
+  budget  int              // evolver calls per decision, 0: unlimited
+  tableSize int            // transposition table slots
+  table   *transTable      // allocated by the first search
+  persist bool             // whether table may serve the next search
+}
+
+// PredictiveOption configures a PredictiveAgent at construction.
+type PredictiveOption func(a *PredictiveAgent)
+
+// WithTableSize sets the number of slots of the agent's transposition
+// table; the default is DefaultTableSize.
+func WithTableSize(slots int) PredictiveOption {
+  if slots <= 0 {
+    panic("table size must be positive")
+  }
+  return func(a *PredictiveAgent) {
+    a.tableSize = slots
+  }
+}
+
+// TableStats reports how the agent's transposition table has served
+// its searches so far.
+func (a *PredictiveAgent) TableStats() TableStats {
+  if a.table == nil {
+    return TableStats{}
+  }
+  return a.table.stats
+}
+
+// WithReducer sets the risk measure that collapses roll‑out outcomes;
+// the default is the worst case.
+func WithReducer(r SurvivalReducer) PredictiveOption {
+  return func(a *PredictiveAgent) {
+    a.reducer = r
+  }
 }
 
 // NewPredictiveAgent creates a foresight-enabled agent.
@@ -132,15 +213,21 @@ func NewPredictiveAgent(
     pos substrates.Pos,
     foresight int,
     rng *substrates.SplitMix64,
+    opts ...PredictiveOption,
 ) *PredictiveAgent {
-  return &PredictiveAgent{
+  a := &PredictiveAgent{
     baseAgent: baseAgent{
       id:        id,
       pos:       pos,
       foresight: foresight,
       rng:       rng,
     },
+    tableSize: DefaultTableSize,
+  }
+  for _, opt := range opts {
+    opt(a)
   }
+  return a
 }
 
 func (a *PredictiveAgent) Decide(
@@ -148,6 +235,5 @@ func (a *PredictiveAgent) Decide(
     evolve substrates.Evolver,
     deterministic bool,
 ) substrates.Move {
-  m := a.predictiveDecide(g, evolve, deterministic)
-  return m
+  return a.DecideContext(context.Background(), g, evolve, deterministic)
 }
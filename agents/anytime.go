@@ -0,0 +1,116 @@
+package agents
+// Anytime planning under a compute budget.
+import "context"
+import "oscarkilo.com/inteluni/substrates"
+
+// ContextAgent is an agent whose thinking can be cut short.  Once ctx
+// is done, DecideContext returns the best move found so far instead of
+// finishing its search.  Decide is DecideContext with a context that is
+// never done.
+//
+// Wall-clock deadlines make decisions depend on the speed of the
+// machine, so runs using them are not reproducible; budgets counted in
+// evolver calls (see WithBudget) are.
+type ContextAgent interface {
+  Agent
+  DecideContext(
+      ctx context.Context,
+      grid *substrates.Grid2d,
+      evolve substrates.Evolver,
+      deterministic bool,
+  ) substrates.Move
+}
+
+// WithBudget caps each decision at calls evolver calls; 0 means no cap.
+func WithBudget(calls int) PredictiveOption {
+  if calls < 0 {
+    panic("budget must not be negative")
+  }
+  return func(a *PredictiveAgent) {
+    a.budget = calls
+  }
+}
+
+// Budget is the cap on evolver calls per decision, 0 if none.
+func (a *PredictiveAgent) Budget() int {
+  return a.budget
+}
+
+// outOfBudget is panicked by a budgeted evolver to abandon a search.
+type outOfBudget struct{}
+
+// DecideContext searches by iterative deepening when the decision is
+// bounded, by a budget or by ctx: it plans 1 step ahead, then 2, and so
+// on up to the agent's foresight, and returns the move of the deepest
+// search that finished.  A search that runs out of budget is abandoned.
+// If even the 1-step search cannot finish, the agent moves like a
+// ReactiveAgent.
+//
+// Unbounded decisions go straight to full foresight, as they always
+// did, so a deeper search never costs the repeated shallow ones.
+//
+// Iterative deepening spends at most about 1/(b−1) more evolver calls
+// than the deepest search alone, b being the branching factor (5 moves
+// times the roll-outs per move), and so turns a fixed-depth planner
+// into an anytime one.
+//
+// References
+// ----------
+// • Korf, R. E. “Depth-First Iterative-Deepening: An Optimal Admissible
+//   Tree Search.” *Artificial Intelligence*, 1985.
+// • Dean, T. & Boddy, M. “An Analysis of Time-Dependent Planning.”
+//   *Proc. AAAI 1988*.
+func (a *PredictiveAgent) DecideContext(
+    ctx context.Context,
+    g *substrates.Grid2d,
+    evolve substrates.Evolver,
+    deterministic bool,
+) substrates.Move {
+  evolve = a.counted(evolve)
+  if a.budget == 0 && ctx.Done() == nil {
+    return a.predictiveDecide(g, evolve, deterministic, a.foresight)
+  }
+  used := 0
+  limited := func(
+      g *substrates.Grid2d,
+      rng *substrates.SplitMix64,
+  ) *substrates.Grid2d {
+    if (a.budget > 0 && used >= a.budget) || ctx.Err() != nil {
+      panic(outOfBudget{})
+    }
+    used++
+    return evolve(g, rng)
+  }
+  var best substrates.Move
+  found := false
+  for depth := 1; depth <= a.foresight; depth++ {
+    m, ok := a.boundedDecide(g, limited, deterministic, depth)
+    if !ok {
+      break
+    }
+    best, found = m, true
+  }
+  if !found {
+    return a.reactiveMove(g)
+  }
+  return best
+}
+
+// boundedDecide plans depth steps ahead, or reports false if evolve ran
+// out of budget first.
+func (a *PredictiveAgent) boundedDecide(
+    g *substrates.Grid2d,
+    evolve substrates.Evolver,
+    deterministic bool,
+    depth int,
+) (m substrates.Move, ok bool) {
+  defer func() {
+    if r := recover(); r != nil {
+      if _, out := r.(outOfBudget); !out {
+        panic(r)
+      }
+      ok = false
+    }
+  }()
+  return a.predictiveDecide(g, evolve, deterministic, depth), true
+}
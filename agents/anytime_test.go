@@ -0,0 +1,57 @@
+package agents
+import "context"
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+// noisyStill evolves a world that stays as it is, drawing from rng so
+// that planners treat it as noisy.
+func noisyStill(g *substrates.Grid2d, rng *substrates.SplitMix64) *substrates.Grid2d {
+  rng.NextUint64()
+  return g.Clone()
+}
+
+func TestPredictiveBudget(t *testing.T) {
+  g := substrates.NewGrid2d(9, 9)
+  g.SetXY(4, 3, substrates.Live)
+  start := substrates.Pos{X: 4, Y: 4}
+  free := NewPredictiveAgent(1, start, 4, substrates.NewSplitMix64(1))
+  free.Decide(g, noisyStill, false)
+  for _, budget := range []int{1, 30, 200} {
+    a := NewPredictiveAgent(2, start, 4, substrates.NewSplitMix64(1),
+        WithBudget(budget))
+    for i := 0; i < 3; i++ {
+      before := a.EvolverCalls()
+      if m := a.Decide(g, noisyStill, false); m == substrates.North {
+        t.Errorf("budget %d: walked into a wall", budget)
+      }
+      if used := a.EvolverCalls() - before; used > budget {
+        t.Errorf("budget %d: %d evolver calls", budget, used)
+      }
+    }
+    if a.EvolverCalls() >= 3*free.EvolverCalls() {
+      t.Errorf("budget %d: %d calls, unbudgeted %d per decision",
+          budget, a.EvolverCalls(), free.EvolverCalls())
+    }
+  }
+}
+
+func TestDecideContextCancelled(t *testing.T) {
+  g := substrates.NewGrid2d(9, 9)
+  g.SetXY(4, 3, substrates.Live)
+  start := substrates.Pos{X: 4, Y: 4}
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  p := NewPredictiveAgent(1, start, 3, substrates.NewSplitMix64(1))
+  if m := p.DecideContext(ctx, g, noisyStill, false); m == substrates.North {
+    t.Errorf("predictive fallback walked into a wall")
+  }
+  if p.EvolverCalls() != 0 {
+    t.Errorf("predictive simulated %d steps past its deadline",
+        p.EvolverCalls())
+  }
+  m := NewMCTSAgent(2, start, 3, 100, substrates.NewSplitMix64(1))
+  m.DecideContext(ctx, g, noisyStill, false)
+  if c := m.EvolverCalls(); c == 0 || c > 3 {
+    t.Errorf("mcts made %d evolver calls, want one simulation", c)
+  }
+}
@@ -0,0 +1,228 @@
+package agents
+// Tabular Q-learning from survival reward.
+import "encoding/json"
+import "fmt"
+import "io"
+import "math"
+import "strconv"
+import "oscarkilo.com/inteluni/substrates"
+
+// LearningAgent is the one agent that is not handed a model of the
+// world: it never calls the Evolver.  It sees only the (2r+1)×(2r+1)
+// window of cells around its position, each either lethal or not, and
+// picks moves from a table of action values learnt by Q-learning
+// (Watkins & Dayan, 1992) over many episodes, with the rewards of
+// PredictiveAgent: aliveReward for every step survived, deathPenalty on
+// death, discounted by gamma.  After the move in state s with action a,
+//
+//     Q(s,a) ← Q(s,a) + α (r + γ max_a' Q(s',a') − Q(s,a))
+//
+// where the max term is dropped if the agent died.  The table is shared
+// by every agent built with it, so a population learns together, and
+// it is saved and loaded as JSON.
+//
+// While learning the agent explores, taking a random move with
+// probability ε.  A frozen table is only read, by any number of agents
+// at once; a learning one must not be shared across goroutines.
+//
+// References
+// ----------
+// • Watkins, C. J. C. H. & Dayan, P. “Q-learning.” *Machine Learning*,
+//   1992.
+// • Sutton, R. S. & Barto, A. G. *Reinforcement Learning: An
+//   Introduction* (2nd ed.). MIT Press, 2018, ch. 6.
+type LearningAgent struct {
+  baseAgent
+  table *QTable
+  learn bool
+  // the last decision, for Outcome
+  state  uint64
+  action int
+}
+
+// Learner is an agent that learns from what its moves led to.  After
+// every step it took part in, the simulation calls Outcome with the
+// grid after the step and whether the agent died in it.
+type Learner interface {
+  Agent
+  Outcome(g *substrates.Grid2d, died bool)
+}
+
+// NewLearningAgent creates an agent acting on table, and updating it
+// if learn is set.
+func NewLearningAgent(
+    id int,
+    pos substrates.Pos,
+    table *QTable,
+    learn bool,
+    rng *substrates.SplitMix64,
+) *LearningAgent {
+  if table == nil {
+    panic("learning agent needs a QTable")
+  }
+  return &LearningAgent{
+    baseAgent: baseAgent{
+      id:  id,
+      pos: pos,
+      rng: rng,
+    },
+    table: table,
+    learn: learn,
+  }
+}
+
+// Decide picks the move with the highest value in the current window,
+// breaking ties at random, or explores while learning.
+func (a *LearningAgent) Decide(
+    g *substrates.Grid2d,
+    _ substrates.Evolver,
+    _ bool,
+) substrates.Move {
+  a.state = a.table.observe(g, a.pos)
+  if a.learn && a.rng.Float64() < a.table.Epsilon {
+    a.action = a.rng.Intn(len(possibleMoves))
+  } else {
+    q := a.table.q[a.state]
+    var best []int
+    for i := range possibleMoves {
+      switch {
+        case len(best) == 0 || q[i] > q[best[0]]:
+          best = append(best[:0], i)
+        case q[i] == q[best[0]]:
+          best = append(best, i)
+      }
+    }
+    a.action = best[a.rng.Intn(len(best))]
+  }
+  return possibleMoves[a.action]
+}
+
+// Outcome applies the Q-learning update for the last decision.
+func (a *LearningAgent) Outcome(g *substrates.Grid2d, died bool) {
+  if !a.learn {
+    return
+  }
+  t := a.table
+  target := deathPenalty
+  if !died {
+    next := t.q[t.observe(g, a.pos)]
+    best := math.Inf(-1)
+    for _, v := range next {
+      best = math.Max(best, v)
+    }
+    target = aliveReward + gamma*best
+  }
+  q := t.q[a.state]
+  q[a.action] += t.Alpha * (target - q[a.action])
+  t.q[a.state] = q
+}
+
+// QTable holds the action values of LearningAgents, by window.
+type QTable struct {
+  Radius  int      // window radius: 1 sees 3×3 cells
+  Alpha   float64  // learning rate
+  Epsilon float64  // exploration rate while learning
+  q       map[uint64][5]float64  // by window, in possibleMoves order
+}
+
+// Defaults for NewQTable.
+const (
+  DefaultQRadius  = 1
+  DefaultQAlpha   = 0.1
+  DefaultQEpsilon = 0.1
+)
+
+// maxQRadius keeps a window within the 64 bits of a state.
+const maxQRadius = 3
+
+// NewQTable returns an empty table, all values 0.  Zero alpha and
+// epsilon take the defaults.
+func NewQTable(radius int, alpha, epsilon float64) *QTable {
+  if radius < 0 || radius > maxQRadius {
+    panic(fmt.Sprintf("window radius %d outside 0..%d", radius, maxQRadius))
+  }
+  if alpha <= 0 {
+    alpha = DefaultQAlpha
+  }
+  if epsilon <= 0 {
+    epsilon = DefaultQEpsilon
+  }
+  return &QTable{
+    Radius:  radius,
+    Alpha:   alpha,
+    Epsilon: epsilon,
+    q:       map[uint64][5]float64{},
+  }
+}
+
+// States is the number of windows the table has values for.
+func (t *QTable) States() int {
+  return len(t.q)
+}
+
+// observe encodes the window around pos, one bit per cell, row by row,
+// set if the cell is lethal.  Cells beyond a Bounded edge count as
+// lethal: nothing can go there; so do unknown cells, to be safe.
+func (t *QTable) observe(g *substrates.Grid2d, pos substrates.Pos) uint64 {
+  var s uint64
+  bit := 0
+  for dy := -t.Radius; dy <= t.Radius; dy++ {
+    for dx := -t.Radius; dx <= t.Radius; dx++ {
+      x, y, ok := g.Resolve(pos.X+dx, pos.Y+dy)
+      if !ok || !g.KnownXY(x, y) || substrates.Lethal(g.XY(x, y)) {
+        s |= 1 << uint(bit)
+      }
+      bit++
+    }
+  }
+  return s
+}
+
+// qTableFile is the JSON form of a QTable.
+type qTableFile struct {
+  Radius  int                   `json:"radius"`
+  Alpha   float64               `json:"alpha"`
+  Epsilon float64               `json:"epsilon"`
+  Q       map[string][5]float64 `json:"q"`  // by window, in decimal
+}
+
+// Save writes the table as JSON.
+func (t *QTable) Save(w io.Writer) error {
+  f := qTableFile{
+    Radius:  t.Radius,
+    Alpha:   t.Alpha,
+    Epsilon: t.Epsilon,
+    Q:       make(map[string][5]float64, len(t.q)),
+  }
+  for s, q := range t.q {
+    f.Q[strconv.FormatUint(s, 10)] = q
+  }
+  enc := json.NewEncoder(w)
+  enc.SetIndent("", " ")
+  return enc.Encode(f)
+}
+
+// LoadQTable reads a table written by Save.
+func LoadQTable(r io.Reader) (*QTable, error) {
+  dec := json.NewDecoder(r)
+  dec.DisallowUnknownFields()
+  var f qTableFile
+  if err := dec.Decode(&f); err != nil {
+    return nil, err
+  }
+  if f.Radius < 0 || f.Radius > maxQRadius || f.Alpha <= 0 || f.Epsilon < 0 {
+    return nil, fmt.Errorf("qtable: bad radius %d, alpha %v or epsilon %v",
+        f.Radius, f.Alpha, f.Epsilon)
+  }
+  t := NewQTable(f.Radius, f.Alpha, f.Epsilon)
+  t.Epsilon = f.Epsilon
+  cells := (2*f.Radius + 1) * (2*f.Radius + 1)
+  for key, q := range f.Q {
+    s, err := strconv.ParseUint(key, 10, 64)
+    if err != nil || s >= 1<<uint(cells) {
+      return nil, fmt.Errorf("qtable: bad state %q", key)
+    }
+    t.q[s] = q
+  }
+  return t, nil
+}
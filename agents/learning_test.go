@@ -0,0 +1,80 @@
+package agents
+import "bytes"
+import "reflect"
+import "strings"
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+func TestLearningAgentAvoidsDeath(t *testing.T) {
+  // a still world with a wall to the north of the start
+  g := substrates.NewGrid2d(5, 5)
+  g.SetXY(2, 1, substrates.Live)
+  start := substrates.Pos{X: 2, Y: 2}
+  table := NewQTable(1, 0.5, 0.5)
+  rng := substrates.NewSplitMix64(3)
+  for i := 0; i < 200; i++ {
+    a := NewLearningAgent(1, start, table, true, rng.NewFromSelf())
+    a.Apply(a.Decide(g, nil, true), g)
+    a.Outcome(g, substrates.Lethal(g.Get(a.Pos())))
+  }
+  q := table.q[table.observe(g, start)]
+  if q[0] > 0.01 || q[1] < 0.9*aliveReward || q[4] <= q[1] {
+    t.Errorf("values at the start %v", q)
+  }
+  frozen := NewLearningAgent(2, start, table, false, rng.NewFromSelf())
+  before := table.q[table.observe(g, start)]
+  for i := 0; i < 50; i++ {
+    m := frozen.Decide(g, nil, true)
+    if m == substrates.North {
+      t.Fatalf("frozen agent walked into the wall")
+    }
+    frozen.Outcome(g, true)
+  }
+  if table.q[table.observe(g, start)] != before {
+    t.Errorf("frozen agent changed the table")
+  }
+}
+
+func TestQTableObserve(t *testing.T) {
+  g := substrates.NewGrid2d(4, 4)
+  g.SetTopology(substrates.Bounded)
+  g.SetXY(1, 0, substrates.Live)
+  table := NewQTable(1, 0, 0)
+  // at the top-left corner the row above and the column to the left
+  // are outside: bits 0, 1, 2, 3 and 6, plus bit 5 for the live cell
+  want := uint64(1<<0 | 1<<1 | 1<<2 | 1<<3 | 1<<5 | 1<<6)
+  if s := table.observe(g, substrates.Pos{X: 0, Y: 0}); s != want {
+    t.Errorf("state %09b, want %09b", s, want)
+  }
+  g.SetTopology(substrates.Torus)
+  if s := table.observe(g, substrates.Pos{X: 1, Y: 1}); s != 1<<1 {
+    t.Errorf("state %09b", s)
+  }
+}
+
+func TestQTableSaveLoad(t *testing.T) {
+  table := NewQTable(2, 0.2, 0.05)
+  table.q[7] = [5]float64{1, 2.5, 0, -1, 9}
+  table.q[1<<24] = [5]float64{0.125}
+  var buf bytes.Buffer
+  if err := table.Save(&buf); err != nil {
+    t.Fatal(err)
+  }
+  back, err := LoadQTable(&buf)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(back, table) {
+    t.Errorf("loaded %+v, want %+v", back, table)
+  }
+  for _, bad := range []string{
+    `{"radius": 9, "alpha": 0.1, "epsilon": 0.1, "q": {}}`,
+    `{"radius": 1, "alpha": 0.1, "epsilon": 0.1, "q": {"512": [0,0,0,0,0]}}`,
+    `{"radius": 1, "alpha": 0.1, "epsilon": 0.1, "q": {"x": [0,0,0,0,0]}}`,
+    `{"radius": 1, "alpha": 0.1, "epsilon": 0.1, "gamma": 1}`,
+  } {
+    if _, err := LoadQTable(strings.NewReader(bad)); err == nil {
+      t.Errorf("loaded %s", bad)
+    }
+  }
+}
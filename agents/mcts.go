@@ -0,0 +1,249 @@
+package agents
+// Monte Carlo Tree Search with the UCT selection rule.
+import "context"
+import "math"
+import "oscarkilo.com/inteluni/substrates"
+
+// MCTSAgent plans by sampling, not by exhaustive search.
+//
+// Each decision runs a fixed budget of simulations.  A simulation walks
+// down a tree of move sequences, picking moves by UCT (Kocsis &
+// Szepesvári, 2006), samples the next world at every step through the
+// provided Evolver, and adds one new node when it leaves the tree.  From
+// there a random rollout continues to the horizon.  Rewards are those of
+// PredictiveAgent: aliveReward per survived step, discounted by gamma,
+// deathPenalty on collision.
+//
+// In a noisy universe the tree is open-loop: a node stands for a move
+// sequence, and each visit samples a fresh future for it.  In a
+// deterministic universe every node caches its grid, so each simulation
+// evolves the world only for the steps that leave the tree.
+//
+// The budget bounds thinking, the horizon bounds how far ahead; with
+// both fixed, compute per decision is about budget × horizon evolver
+// calls, comparable to PredictiveAgent at a matching foresight.
+//
+// References
+// ----------
+// • Kocsis, L. & Szepesvári, C. “Bandit Based Monte‑Carlo Planning.”
+//   *Proc. ECML 2006*.
+// • Browne, C. B. et al. “A Survey of Monte Carlo Tree Search Methods.”
+//   *IEEE Trans. Comp. Intell. AI Games*, 2012.
+type MCTSAgent struct {
+  baseAgent
+  budget int  // simulations per decision
+}
+
+// uctExploration is the UCT constant applied to returns normalised
+// to [0, 1]; √2 is the classic choice for rewards in that range.
+const uctExploration = math.Sqrt2
+
+// NewMCTSAgent creates an agent that looks horizon steps ahead using
+// budget simulations per decision.
+func NewMCTSAgent(
+    id int,
+    pos substrates.Pos,
+    horizon int,
+    budget int,
+    rng *substrates.SplitMix64,
+) *MCTSAgent {
+  if horizon <= 0 {
+    panic("horizon must be positive")
+  }
+  if budget <= 0 {
+    panic("budget must be positive")
+  }
+  return &MCTSAgent{
+    baseAgent: baseAgent{
+      id:        id,
+      pos:       pos,
+      foresight: horizon,
+      rng:       rng,
+    },
+    budget: budget,
+  }
+}
+
+func (a *MCTSAgent) Budget() int {
+  return a.budget
+}
+
+// mctsNode holds the statistics of the move leading into it.
+type mctsNode struct {
+  children [5]*mctsNode  // indexed like possibleMoves, nil if unexpanded
+  expanded int           // non-nil children
+  visits   int
+  total    float64       // sum of normalised returns from this node
+  grid     *substrates.Grid2d  // cached future, deterministic only
+}
+
+func (a *MCTSAgent) Decide(
+    g *substrates.Grid2d,
+    evolve substrates.Evolver,
+    deterministic bool,
+) substrates.Move {
+  return a.DecideContext(context.Background(), g, evolve, deterministic)
+}
+
+// DecideContext is Decide, but stops simulating once ctx is done.  The
+// first simulation always runs, so there is a move to return.
+func (a *MCTSAgent) DecideContext(
+    ctx context.Context,
+    g *substrates.Grid2d,
+    evolve substrates.Evolver,
+    deterministic bool,
+) substrates.Move {
+  evolve = a.counted(evolve)
+  root := &mctsNode{}
+  // unknown cells are sampled afresh by every simulation, so the tree
+  // cannot cache futures
+  partial := g.Partial()
+  deterministic = deterministic && !partial
+  for i := 0; i < a.budget && (i == 0 || ctx.Err() == nil); i++ {
+    world := g
+    if partial {
+      world = fillUnknown(g, a.rng)
+    }
+    a.simulate(root, world, evolve, deterministic)
+  }
+  // robust child: the most visited move; ties are drawn with the
+  // agent's rng, like pickMove's, so that a small budget does not
+  // favour the first of possibleMoves
+  var tied []int
+  bestVisits := -1
+  for i, child := range root.children {
+    switch {
+      case child == nil || child.visits < bestVisits:
+      case child.visits > bestVisits:
+        tied, bestVisits = []int{i}, child.visits
+      default:
+        tied = append(tied, i)
+    }
+  }
+  if len(tied) == 1 {
+    return possibleMoves[tied[0]]
+  }
+  return possibleMoves[tied[a.rng.Intn(len(tied))]]
+}
+
+// expand adds a child for a move drawn from those node has not tried.
+func (a *MCTSAgent) expand(node *mctsNode) int {
+  var untried []int
+  for i, child := range node.children {
+    if child == nil {
+      untried = append(untried, i)
+    }
+  }
+  i := untried[0]
+  if len(untried) > 1 {
+    i = untried[a.rng.Intn(len(untried))]
+  }
+  node.children[i] = &mctsNode{}
+  node.expanded++
+  return i
+}
+
+// simulate runs one selection, expansion, rollout and backup pass.
+func (a *MCTSAgent) simulate(
+    root *mctsNode,
+    g *substrates.Grid2d,
+    evolve substrates.Evolver,
+    deterministic bool,
+) {
+  horizon := a.foresight
+  path := make([]*mctsNode, 0, horizon)
+  rewards := make([]float64, 0, horizon)
+  node, grid, pos := root, g, a.pos
+  alive := true
+  for depth := 0; depth < horizon && alive; depth++ {
+    var i int
+    fresh := node.expanded < len(possibleMoves)
+    if fresh {
+      i = a.expand(node)
+    } else {
+      i = selectUCT(node)
+    }
+    child := node.children[i]
+    pos = grid.Step(pos, possibleMoves[i])
+    if deterministic && child.grid != nil {
+      grid = child.grid
+    } else {
+      grid = evolve(grid, a.rng)
+      if deterministic {
+        child.grid = grid
+      }
+    }
+    alive = !substrates.Lethal(grid.Get(pos))
+    rewards = append(rewards, stepReward(alive))
+    path = append(path, child)
+    node = child
+    if fresh {
+      break
+    }
+  }
+  if alive {
+    rewards = a.rollout(grid, pos, horizon-len(rewards), evolve, rewards)
+  }
+  // back up: the node at depth d scores the return from step d on
+  ret := 0.0
+  for d := len(rewards) - 1; d >= 0; d-- {
+    ret = rewards[d] + gamma*ret
+    if d < len(path) {
+      path[d].visits++
+      path[d].total += ret / maxReturn(horizon-d)
+    }
+  }
+  root.visits++
+}
+
+// selectUCT picks the child maximising mean + c·√(ln N / n).
+func selectUCT(node *mctsNode) int {
+  best := 0
+  bestScore := math.Inf(-1)
+  logN := math.Log(float64(node.visits))
+  for i, child := range node.children {
+    if child.visits == 0 {
+      return i
+    }
+    mean := child.total / float64(child.visits)
+    score := mean + uctExploration*math.Sqrt(logN/float64(child.visits))
+    if score > bestScore {
+      best, bestScore = i, score
+    }
+  }
+  return best
+}
+
+// rollout continues with uniformly random moves for up to steps more
+// steps, appending the rewards it collects.
+func (a *MCTSAgent) rollout(
+    grid *substrates.Grid2d,
+    pos substrates.Pos,
+    steps int,
+    evolve substrates.Evolver,
+    rewards []float64,
+) []float64 {
+  for t := 0; t < steps; t++ {
+    m := possibleMoves[a.rng.Intn(len(possibleMoves))]
+    grid = evolve(grid, a.rng)
+    pos = grid.Step(pos, m)
+    alive := !substrates.Lethal(grid.Get(pos))
+    rewards = append(rewards, stepReward(alive))
+    if !alive {
+      break
+    }
+  }
+  return rewards
+}
+
+func stepReward(alive bool) float64 {
+  if alive {
+    return aliveReward
+  }
+  return deathPenalty
+}
+
+// maxReturn is the discounted return of surviving all of steps steps.
+func maxReturn(steps int) float64 {
+  return aliveReward * (1 - math.Pow(gamma, float64(steps))) / (1 - gamma)
+}
@@ -0,0 +1,120 @@
+package agents
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+func TestMCTSAvoidsObstacle(t *testing.T) {
+  // Only the cell north of (1,1) stays open in every future.
+  future := asciiToGrid([]string{
+    "#_#",
+    "###",
+    "###",
+  })
+  ag := NewMCTSAgent(1, substrates.Pos{X: 1, Y: 1}, 3, 50,
+      substrates.NewSplitMix64(7))
+  move := ag.Decide(substrates.NewGrid2d(3, 3),
+      alwaysSameEvolver(future), true)
+  if move != substrates.North {
+    t.Fatalf("expected North, got %v", move)
+  }
+}
+
+func TestMCTSNoisyPrefersSaferMove(t *testing.T) {
+  // North is safe in both possible futures, East in only one.
+  grids := []*substrates.Grid2d{
+    asciiToGrid([]string{
+      "_#_#",
+      "_##_",
+      "####",
+    }),
+    asciiToGrid([]string{
+      "_#_#",
+      "_###",
+      "####",
+    }),
+  }
+  ag := NewMCTSAgent(2, substrates.Pos{X: 2, Y: 1}, 2, 400,
+      substrates.NewSplitMix64(3))
+  move := ag.Decide(grids[0], randomEvolver(grids), false)
+  if move != substrates.North {
+    t.Fatalf("expected North, got %v", move)
+  }
+}
+
+func TestMCTSReproducible(t *testing.T) {
+  g := asciiToGrid([]string{
+    "_#__#",
+    "__#__",
+    "#___#",
+    "__#__",
+  })
+  evolver := func(src *substrates.Grid2d, rng *substrates.SplitMix64) *substrates.Grid2d {
+    dup := src.Clone()
+    dup.Map(func(_, _, v int) int {
+      if rng.Float64() < 0.3 {
+        return 1 - v
+      }
+      return v
+    })
+    return dup
+  }
+  decide := func() []substrates.Move {
+    ag := NewMCTSAgent(3, substrates.Pos{X: 1, Y: 1}, 4, 60,
+        substrates.NewSplitMix64(11))
+    var moves []substrates.Move
+    for i := 0; i < 5; i++ {
+      moves = append(moves, ag.Decide(g, evolver, false))
+    }
+    return moves
+  }
+  a, b := decide(), decide()
+  for i := range a {
+    if a[i] != b[i] {
+      t.Fatalf("decision %d differs between identical agents", i)
+    }
+  }
+}
+
+func TestMCTSBudgetBoundsEvolverCalls(t *testing.T) {
+  calls := 0
+  evolver := func(src *substrates.Grid2d, _ *substrates.SplitMix64) *substrates.Grid2d {
+    calls++
+    return src
+  }
+  const budget, horizon = 30, 4
+  ag := NewMCTSAgent(4, substrates.Pos{X: 0, Y: 0}, horizon, budget,
+      substrates.NewSplitMix64(1))
+  ag.Decide(substrates.NewGrid2d(4, 4), evolver, false)
+  if calls > budget*horizon {
+    t.Fatalf("%d evolver calls exceed budget×horizon = %d",
+        calls, budget*horizon)
+  }
+}
+
+// TestMCTSSmallBudgetNotNorth checks that a budget too small to try
+// every move does not always try North first.
+func TestMCTSSmallBudgetNotNorth(t *testing.T) {
+  g := substrates.NewGrid2d(5, 5)
+  seen := map[substrates.Move]bool{}
+  for seed := uint64(0); seed < 20; seed++ {
+    ag := NewMCTSAgent(1, substrates.Pos{X: 2, Y: 2}, 2, 1,
+        substrates.NewSplitMix64(seed))
+    seen[ag.Decide(g, alwaysSameEvolver(g), true)] = true
+  }
+  if len(seen) < 3 {
+    t.Fatalf("budget 1 yielded only moves %v", seen)
+  }
+}
+
+func TestSpawnPopulationKinds(t *testing.T) {
+  g := substrates.NewGrid2d(6, 6)
+  pop := Population{Reactive: 2, Predictive: 3, MCTS: 4,
+      Foresight: 2, MCTSBudget: 10}
+  counts := map[string]int{}
+  for _, ag := range SpawnPopulation(g, pop, substrates.NewSplitMix64(5)) {
+    counts[Kind(ag)]++
+  }
+  if counts[KindReactive] != 2 || counts[KindPredictive] != 3 ||
+      counts[KindMCTS] != 4 {
+    t.Fatalf("unexpected population %v", counts)
+  }
+}
@@ -0,0 +1,276 @@
+package agents
+// Planning with a learnt model of the universe.
+import "context"
+import "oscarkilo.com/inteluni/substrates"
+
+// ModelAgent plans like PredictiveAgent, but never calls the Evolver it
+// is handed.  It watches the frames go by and fits its own WorldModel
+// of how cells change, and looks ahead with that.  Comparing it with
+// PredictiveAgent at the same foresight separates what lookahead is
+// worth from what knowing the exact physics is worth.
+//
+// The model starts empty, predicting that nothing changes, and learns
+// from every pair of consecutive frames the agent lives through; it is
+// not carried over between runs.  Under limited perception it learns
+// from the cells it saw in both frames.
+type ModelAgent struct {
+  PredictiveAgent
+  model *WorldModel
+  prev  *substrates.Grid2d  // the frame of the last decision
+}
+
+// NewModelAgent creates an agent that learns a model of the universe
+// and plans with it foresight steps ahead.
+func NewModelAgent(
+    id int,
+    pos substrates.Pos,
+    foresight int,
+    rng *substrates.SplitMix64,
+    opts ...PredictiveOption,
+) *ModelAgent {
+  return &ModelAgent{
+    PredictiveAgent: *NewPredictiveAgent(id, pos, foresight, rng, opts...),
+    model:           NewWorldModel(),
+  }
+}
+
+// Model returns the agent's world model.
+func (a *ModelAgent) Model() *WorldModel {
+  return a.model
+}
+
+// Decide fits the transition from the previous frame to g, then plans
+// with the model.  The true evolver is ignored, and so is whether the
+// universe is deterministic: the model is deterministic if it has seen
+// no noise.
+func (a *ModelAgent) Decide(
+    g *substrates.Grid2d,
+    evolve substrates.Evolver,
+    deterministic bool,
+) substrates.Move {
+  return a.DecideContext(context.Background(), g, evolve, deterministic)
+}
+
+// DecideContext is Decide under the agent's budget and ctx, like
+// PredictiveAgent.DecideContext.  Fitting the model is not budgeted.
+func (a *ModelAgent) DecideContext(
+    ctx context.Context,
+    g *substrates.Grid2d,
+    _ substrates.Evolver,
+    _ bool,
+) substrates.Move {
+  if a.prev != nil {
+    a.model.Fit(a.prev, g)
+    a.persist = false  // values cached under the old model are stale
+  }
+  a.prev = g.Clone()
+  return a.PredictiveAgent.DecideContext(
+      ctx, g, a.model.Evolve, a.model.Noise() == 0)
+}
+
+// WorldModel is an empirical transition model of a cellular universe.
+// For every 3×3 window it has seen it counts the states the centre cell
+// took one tick later, and predicts the most frequent one (the earliest
+// seen, on a tie); a window never seen predicts that the centre keeps
+// its state.  What the majority rule gets wrong it treats as noise: the
+// noise rate is the fraction of fitted transitions that disagree with
+// their window's majority, and Evolve flips that fraction of cells to a
+// different state, drawn by how often each state was seen as a next
+// state.
+//
+// For a deterministic universe with at most 16 states, whose rule
+// depends only on the Moore neighbourhood (Life-like and Generations
+// rules), the majority rule is exact once every window has been seen;
+// for noisy universes the noise rate approaches the chance that noise
+// changed a cell.  Cells beyond a Bounded edge are read as Empty, and
+// states above 15 as 15.
+//
+// References
+// ----------
+// • Sutton, R. S. “Dyna, an Integrated Architecture for Learning,
+//   Planning, and Reacting.” *SIGART Bulletin*, 1991.
+// • Adamatzky, A. *Identification of Cellular Automata.* Taylor &
+//   Francis, 1994.
+type WorldModel struct {
+  windows   map[uint64]*windowCounts
+  next      [maxModelState + 1]int  // next states over all transitions
+  fitted    int  // transitions counted
+  disagree  int  // of which not their window's majority
+  predicted int  // cells predicted by Fit before counting them
+  correct   int  // of which right
+}
+
+// maxModelState is the largest state a window distinguishes; each cell
+// of a window takes 4 bits of its key.
+const maxModelState = 15
+
+type windowCounts struct {
+  counts [maxModelState + 1]int
+  total  int
+  major  int  // most frequent next state
+}
+
+// NewWorldModel returns an empty model.
+func NewWorldModel() *WorldModel {
+  return &WorldModel{windows: map[uint64]*windowCounts{}}
+}
+
+// Fit counts the transition of every cell from g to next, which must
+// have the same shape, skipping cells that are unknown in next or whose
+// window is not wholly known in g.  Before counting it predicts each
+// cell, for Accuracy.
+func (m *WorldModel) Fit(g, next *substrates.Grid2d) {
+  if g.W() != next.W() || g.H() != next.H() {
+    panic("frames of different shapes")
+  }
+  partial := g.Partial() || next.Partial()
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      if partial && !(next.KnownXY(x, y) && windowKnown(g, x, y)) {
+        continue
+      }
+      key := windowKey(g, x, y)
+      s := modelState(next.XY(x, y))
+      m.predicted++
+      if m.predict(key) == s {
+        m.correct++
+      }
+      w, ok := m.windows[key]
+      if !ok {
+        w = &windowCounts{major: s}
+        m.windows[key] = w
+      }
+      m.disagree -= w.total - w.counts[w.major]
+      w.counts[s]++
+      w.total++
+      if w.counts[s] > w.counts[w.major] {
+        w.major = s
+      }
+      m.disagree += w.total - w.counts[w.major]
+      m.next[s]++
+      m.fitted++
+    }
+  }
+}
+
+// Evolve predicts the grid after g, as an Evolver.  It draws from rng
+// only if the noise rate is positive.
+func (m *WorldModel) Evolve(
+    g *substrates.Grid2d,
+    rng *substrates.SplitMix64,
+) *substrates.Grid2d {
+  noise := m.Noise()
+  next := g.Clone()
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      s := m.predict(windowKey(g, x, y))
+      if noise > 0 && rng.Float64() < noise {
+        s = m.flip(s, rng)
+      }
+      next.SetXY(x, y, s)
+    }
+  }
+  return next
+}
+
+// Noise is the fraction of fitted transitions that disagree with their
+// window's majority, 0 before any.
+func (m *WorldModel) Noise() float64 {
+  if m.fitted == 0 {
+    return 0
+  }
+  return float64(m.disagree) / float64(m.fitted)
+}
+
+// Accuracy is the fraction of cells Fit predicted right before
+// counting them, 0 before any.  This is the model's out-of-sample
+// accuracy, each frame pair being predicted from the frames before it.
+func (m *WorldModel) Accuracy() float64 {
+  if m.predicted == 0 {
+    return 0
+  }
+  return float64(m.correct) / float64(m.predicted)
+}
+
+// Predicted is how many cells Fit predicted.
+func (m *WorldModel) Predicted() int {
+  return m.predicted
+}
+
+// Correct is how many of the Predicted cells were right.
+func (m *WorldModel) Correct() int {
+  return m.correct
+}
+
+// Windows is the number of distinct windows seen.
+func (m *WorldModel) Windows() int {
+  return len(m.windows)
+}
+
+// predict returns the most likely next state of the centre of window
+// key: its majority, or the centre's own state if never seen.
+func (m *WorldModel) predict(key uint64) int {
+  if w, ok := m.windows[key]; ok {
+    return w.major
+  }
+  return int(key >> (4 * 4) & maxModelState)
+}
+
+// flip draws a next state other than s, weighted by how often each was
+// seen; s if no other state ever was.
+func (m *WorldModel) flip(s int, rng *substrates.SplitMix64) int {
+  total := m.fitted - m.next[s]
+  if total == 0 {
+    return s
+  }
+  r := rng.Intn(total)
+  for t, n := range m.next {
+    if t == s {
+      continue
+    }
+    if r < n {
+      return t
+    }
+    r -= n
+  }
+  panic("unreachable")
+}
+
+// windowKey packs the 3×3 window centred on (x, y), row by row, 4 bits
+// per cell.  The centre is cell 4.
+func windowKey(g *substrates.Grid2d, x, y int) uint64 {
+  var key uint64
+  shift := uint(0)
+  for dy := -1; dy <= 1; dy++ {
+    for dx := -1; dx <= 1; dx++ {
+      s := substrates.Empty
+      if nx, ny, ok := g.Resolve(x+dx, y+dy); ok {
+        s = modelState(g.XY(nx, ny))
+      }
+      key |= uint64(s) << shift
+      shift += 4
+    }
+  }
+  return key
+}
+
+// windowKnown reports whether every cell of the 3×3 window centred on
+// (x, y) is known; cells beyond a Bounded edge count as known.
+func windowKnown(g *substrates.Grid2d, x, y int) bool {
+  for dy := -1; dy <= 1; dy++ {
+    for dx := -1; dx <= 1; dx++ {
+      nx, ny, ok := g.Resolve(x+dx, y+dy)
+      if ok && !g.KnownXY(nx, ny) {
+        return false
+      }
+    }
+  }
+  return true
+}
+
+func modelState(v int) int {
+  if v > maxModelState {
+    return maxModelState
+  }
+  return v
+}
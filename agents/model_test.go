@@ -0,0 +1,91 @@
+package agents
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+// conwayRule is B3/S23 as LifeStep masks.
+const (
+  conwayBirth   = 1 << 3
+  conwaySurvive = 1<<2 | 1<<3
+)
+
+func randomGrid(w, h int, rng *substrates.SplitMix64) *substrates.Grid2d {
+  g := substrates.NewGrid2d(w, h)
+  g.Map(func(x, y, val int) int {
+    if rng.Float64() < 0.4 {
+      return substrates.Live
+    }
+    return substrates.Empty
+  })
+  return g
+}
+
+func TestWorldModelLearnsLife(t *testing.T) {
+  rng := substrates.NewSplitMix64(7)
+  m := NewWorldModel()
+  if got := m.Evolve(randomGrid(8, 8, rng), rng); got == nil {
+    t.Fatal("empty model evolved nothing")
+  }
+  for i := 0; i < 20; i++ {
+    g := randomGrid(32, 32, rng)
+    m.Fit(g, substrates.LifeStep(g, conwayBirth, conwaySurvive))
+  }
+  if m.Noise() != 0 || m.Windows() != 512 {
+    t.Errorf("noise %v over %d windows", m.Noise(), m.Windows())
+  }
+  if a := m.Accuracy(); a < 0.8 || a >= 1 {
+    t.Errorf("accuracy %v while learning", a)
+  }
+  g := randomGrid(16, 16, rng)
+  if !m.Evolve(g, nil).Equal(substrates.LifeStep(g, conwayBirth, conwaySurvive)) {
+    t.Errorf("learnt model disagrees with Life")
+  }
+}
+
+func TestWorldModelNoise(t *testing.T) {
+  rng := substrates.NewSplitMix64(11)
+  m := NewWorldModel()
+  flipped, cells := 0, 0
+  for i := 0; i < 20; i++ {
+    g := randomGrid(32, 32, rng)
+    next := substrates.LifeStep(g, conwayBirth, conwaySurvive)
+    next.Map(func(x, y, val int) int {
+      cells++
+      if rng.Float64() < 0.05 {
+        flipped++
+        return 1 - val
+      }
+      return val
+    })
+    m.Fit(g, next)
+  }
+  want := float64(flipped) / float64(cells)
+  if n := m.Noise(); n < want/2 || n > want*1.5 {
+    t.Errorf("noise %v, flipped %v", n, want)
+  }
+  g := randomGrid(16, 16, rng)
+  if m.Evolve(g, rng).Equal(m.Evolve(g, rng)) {
+    t.Errorf("noisy model evolved the same twice")
+  }
+}
+
+func TestModelAgentIgnoresEvolver(t *testing.T) {
+  g := substrates.NewGrid2d(5, 5)
+  g.SetXY(2, 1, substrates.Live)
+  a := NewModelAgent(1, substrates.Pos{X: 2, Y: 2}, 2,
+      substrates.NewSplitMix64(1))
+  panics := func(*substrates.Grid2d, *substrates.SplitMix64) *substrates.Grid2d {
+    panic("model agent called the true evolver")
+  }
+  for i := 0; i < 3; i++ {
+    if m := a.Decide(g, panics, true); m == substrates.North {
+      t.Fatalf("walked into a still wall")
+    }
+  }
+  if a.Model().Predicted() != 2*25 || a.Model().Accuracy() != 1 {
+    t.Errorf("predicted %d cells, accuracy %v",
+        a.Model().Predicted(), a.Model().Accuracy())
+  }
+  if Kind(a) != KindModel {
+    t.Errorf("kind %q", Kind(a))
+  }
+}
@@ -1,7 +1,5 @@
 package agents
 // Predictive planning with discounted‑survival reward.
-import "strings"
-import "fmt"
 import "oscarkilo.com/inteluni/substrates"
 import "math"
 
@@ -23,11 +21,11 @@ const (
 // γ → 0.
 //
 // Multiple roll‑outs per move generate an outcome distribution; we
-// collapse that distribution with a *survivalReducer*:
+// collapse that distribution with a *SurvivalReducer*:
 //   • worst‑case  - robust control (Howard & Matheson, 1972)
 //   • mean‑case   - expected utility
-// Possible future reducers:
-//   • CVaR or other risk function  (Tamar et al., 2015).
+//   • CVaR, entropic risk and quantiles, which sweep between the
+//     two (Tamar et al., 2015); see risk.go.
 //
 // References
 // ----------
@@ -38,11 +36,20 @@ const (
 //   Processes.” *Management Science*, 1972.
 // • Tamar, A. et al. “Policy Gradient for Coherent Risk Measures.”
 //   *Proc. ICML 2015*.
-
-  memoize = true
 )
 
-type survivalReducer func(
+// Search nodes are cached in the agent's transposition table (see
+// transTable).  In a deterministic universe a node's value is exact, so
+// every node with depth left is cached, and the table is kept from one
+// decision to the next.  In a noisy one a cached value is a single
+// sample of a random subtree, reused in place of fresh roll-outs, so
+// only deep nodes, whose roll-outs cost most, are cached, and the table
+// is cleared before every search.
+const noisyMemoDepth = 4
+
+// SurvivalReducer collapses the outcomes of each move to one score and
+// returns the best move with its score.
+type SurvivalReducer func(
     scoreByMove map[substrates.Move][]float64,
 ) (substrates.Move, float64)
 
@@ -50,47 +57,51 @@ func worstCaseReducer(
     scoreByMove map[substrates.Move][]float64,
 ) (substrates.Move, float64) {
   // find the move with highest low score
-  bestMove := substrates.Stay
-  bestScore := -1.0
-  for move, scores := range scoreByMove {
-    if len(scores) == 0 {
-      panic("no scores for move")
-    }
+  return reduceBy(scoreByMove, func(scores []float64) float64 {
     worstScore := scores[0]
     for _, score := range scores[1:] {
       if score < worstScore {
         worstScore = score
       }
     }
-    if worstScore > bestScore {
-      bestMove = move
-      bestScore = worstScore
-    }
-  }
-  if bestScore < 0 {
-    panic("no valid moves found")
-  }
-  return bestMove, bestScore
+    return worstScore
+  })
 }
 
 func meanCaseReducer(
     scoreByMove map[substrates.Move][]float64,
 ) (substrates.Move, float64) {
   // find the move with highest mean score
+  return reduceBy(scoreByMove, func(scores []float64) float64 {
+    sum := 0.0
+    for _, score := range scores {
+      sum += score
+    }
+    return sum / float64(len(scores))
+  })
+}
+
+// reduceBy scores every move with risk and returns the best one.
+// Moves are visited in possibleMoves order and ties go to the earlier
+// move, so the choice never depends on map iteration order; agents
+// break ties at the root themselves (see pickMove).
+func reduceBy(
+    scoreByMove map[substrates.Move][]float64,
+    risk func(scores []float64) float64,
+) (substrates.Move, float64) {
   bestMove := substrates.Stay
   bestScore := -1.0
-  for move, scores := range scoreByMove {
+  for _, move := range possibleMoves {
+    scores, ok := scoreByMove[move]
+    if !ok {
+      continue
+    }
     if len(scores) == 0 {
       panic("no scores for move")
     }
-    sum := 0.0
-    for _, score := range scores {
-      sum += score
-    }
-    meanScore := sum / float64(len(scores))
-    if meanScore > bestScore {
+    if score := risk(scores); score > bestScore {
       bestMove = move
-      bestScore = meanScore
+      bestScore = score
     }
   }
   if bestScore < 0 {
@@ -99,16 +110,6 @@ func meanCaseReducer(
   return bestMove, bestScore
 }
 
-func toroidal(
-    pos substrates.Pos,
-    m substrates.Move,
-    W, H int,
-) substrates.Pos {
-  x := (pos.X + m.DX() + W) % W
-  y := (pos.Y + m.DY() + H) % H
-  return substrates.Pos{X: x, Y: y}
-}
-
 var possibleMoves = []substrates.Move{
     substrates.North,
     substrates.South,
@@ -117,30 +118,41 @@ var possibleMoves = []substrates.Move{
     substrates.Stay,
 }
 
-type memoEntry struct {
-  move  substrates.Move
-  score float64
-}
-
+// predictiveDecide looks foresight steps ahead.
 func (a *PredictiveAgent) predictiveDecide(
     g *substrates.Grid2d,
     evolve substrates.Evolver,
     deterministic bool,
+    foresight int,
 ) substrates.Move {
-  memo := make(map[string]memoEntry)
-  reducer := worstCaseReducer
-  // reducer := meanCaseReducer
-  if a.foresight <= 0 {
+  reducer := a.reducer
+  if reducer == nil {
+    reducer = worstCaseReducer
+  }
+  if foresight <= 0 {
     panic("foresight must be positive")
   }
-  depthLeft := a.foresight
-  maxDepth := a.foresight
+  // unknown cells are sampled afresh by every root roll-out
+  deterministic = deterministic && !g.Partial()
+  if a.table == nil {
+    a.table = newTransTable(a.tableSize)
+  }
+  if !deterministic || !a.persist {
+    a.table.clear()
+  }
+  a.persist = deterministic
+  memoDepth := noisyMemoDepth
+  if deterministic {
+    memoDepth = 1
+  }
+  depthLeft := foresight
+  maxDepth := foresight
   moveToScore := make(map[substrates.Move][]float64)
   runs := rolloutBudget(depthLeft, maxDepth, deterministic)
   for i := 0; i < runs; i++ {
-    possibleFuture := evolve(g, a.rng)
+    possibleFuture := evolve(fillUnknown(g, a.rng), a.rng)
     for _, m := range possibleMoves {
-      posInPossibleFuture := toroidal(a.pos, m, g.W(), g.H())
+      posInPossibleFuture := possibleFuture.Step(a.pos, m)
       score := a.evaluate(
           possibleFuture,
           posInPossibleFuture,
@@ -148,13 +160,39 @@ func (a *PredictiveAgent) predictiveDecide(
           maxDepth,
           evolve,
           deterministic,
-          memo,
+          memoDepth,
           reducer)
       moveToScore[m] = append(moveToScore[m], score)
     }
   }
-  bestMove, _ := reducer(moveToScore)
-  return bestMove
+  return pickMove(reducer, moveToScore, a.rng)
+}
+
+// pickMove returns a move the reducer scores best, drawing one with rng
+// if several tie.  Ties are common, for instance when every move
+// survives the horizon, and giving them all to the first move would
+// make agents drift that way.  rng is drawn from only on a tie.
+func pickMove(
+    reducer SurvivalReducer,
+    scoreByMove map[substrates.Move][]float64,
+    rng *substrates.SplitMix64,
+) substrates.Move {
+  _, bestScore := reducer(scoreByMove)
+  var tied []substrates.Move
+  for _, m := range possibleMoves {
+    scores, ok := scoreByMove[m]
+    if !ok {
+      continue
+    }
+    one := map[substrates.Move][]float64{m: scores}
+    if _, score := reducer(one); score == bestScore {
+      tied = append(tied, m)
+    }
+  }
+  if len(tied) == 1 {
+    return tied[0]
+  }
+  return tied[rng.Intn(len(tied))]
 }
 
 func (a *PredictiveAgent) evaluate(
@@ -163,29 +201,24 @@ func (a *PredictiveAgent) evaluate(
     depthLeft, maxDepth int,
     evolve substrates.Evolver,
     deterministic bool,
-    memo map[string]memoEntry,
-    reducer survivalReducer,
+    memoDepth int,
+    reducer SurvivalReducer,
 ) float64 {
   if depthLeft < 0 {
     panic("depthLeft must be non-negative")
   }
-  if depthLeft == 0 {
-    if g.Get(pos) == 1 {
-      return deathPenalty
-    }
-    if g.Get(pos) == 0 {
-      return aliveReward
-    }
-    panic("invalid grid value at position")
-  }
-  if g.Get(pos) == 1 {
+  if substrates.Lethal(g.Get(pos)) {
     return deathPenalty
   }
-  var key string
-  if memoize && depthLeft > 3 {
-    key = stateKey(g, pos, depthLeft)
-    if entry, ok := memo[key]; ok {
-      return entry.score
+  if depthLeft == 0 {
+    return aliveReward
+  }
+  memo := depthLeft >= memoDepth
+  var key uint64
+  if memo {
+    key = ttKey(g, pos, depthLeft)
+    if score, ok := a.table.probe(key); ok {
+      return score
     }
   }
   moveToScore := make(map[substrates.Move][]float64)
@@ -193,7 +226,7 @@ func (a *PredictiveAgent) evaluate(
   for i := 0; i < runs; i++ {
     possibleFuture := evolve(g, a.rng)
     for _, m := range possibleMoves {
-      nextPos := toroidal(pos, m, g.W(), g.H())
+      nextPos := possibleFuture.Step(pos, m)
       score := a.evaluate(
           possibleFuture,
           nextPos,
@@ -201,32 +234,17 @@ func (a *PredictiveAgent) evaluate(
           maxDepth,
           evolve,
           deterministic,
-          memo,
+          memoDepth,
           reducer,)
       moveToScore[m] = append(moveToScore[m], score)
     }
   }
-  bestMove, bestScore := reducer(moveToScore)
-  if memoize && depthLeft > 3 {
-    memo[key] = memoEntry{ move: bestMove, score: bestScore }
-  }
-  return gamma * bestScore + aliveReward
-}
-
-func stateKey(
-    g      *substrates.Grid2d,
-    pos    substrates.Pos,
-    depth  int,
-) string {
-  var b strings.Builder
-  for y := 0; y < g.H(); y++ {
-    for x := 0; x < g.W(); x++ {
-      b.WriteByte('0' + byte(g.XY(x, y)))
-    }
-    b.WriteByte('|')
+  _, bestScore := reducer(moveToScore)
+  score := gamma * bestScore + aliveReward
+  if memo {
+    a.table.store(key, depthLeft, score)
   }
-  b.WriteString(fmt.Sprintf("P%d,%d|D%d|", pos.X, pos.Y, depth))
-  return b.String()
+  return score
 }
 
 func rolloutBudget(depth, horizon int, deterministic bool) int {
@@ -35,24 +35,37 @@ func TestMeanCaseReducer(t *testing.T) {
 
 func TestToroidal(t *testing.T) {
   pos := substrates.Pos{X: 0, Y: 0}
-  got := toroidal(pos, substrates.West, 5, 5)
+  got := substrates.NewGrid2d(5, 5).Step(pos, substrates.West)
   want := substrates.Pos{X: 4, Y: 0}
   if got != want {
     t.Fatalf("expected %v, got %v", want, got)
   }
 }
 
-// TestStateKey verifies stability and sensitivity of the key builder.
-func TestStateKey(t *testing.T) {
-  g := substrates.NewGrid2d(2, 2)
-  k1 := stateKey(g, substrates.Pos{X: 0, Y: 0}, 1)
-  k2 := stateKey(g, substrates.Pos{X: 0, Y: 0}, 1)
-  if k1 != k2 {
-    t.Fatalf("identical states yielded different keys")
-  }
-  k3 := stateKey(g, substrates.Pos{X: 1, Y: 0}, 1)
-  if k1 == k3 {
-    t.Fatalf("different states yielded identical keys")
+func TestPredictiveRespectsTopology(t *testing.T) {
+  // The only safe cell is reached from (0,0) by wrapping West.
+  future := asciiToGrid([]string{
+    "##_",
+    "###",
+    "###",
+  })
+  ag := &PredictiveAgent{
+    baseAgent: baseAgent{
+      id:        4,
+      pos:       substrates.Pos{X: 0, Y: 0},
+      foresight: 1,
+      rng:       substrates.NewSplitMix64(0),
+    },
+  }
+  now := substrates.NewGrid2d(3, 3)
+  move := ag.predictiveDecide(now, alwaysSameEvolver(future), true, 1)
+  if move != substrates.West {
+    t.Fatalf("torus: expected West, got %v", move)
+  }
+  now.SetTopology(substrates.Bounded)
+  ag.Apply(substrates.West, now)
+  if ag.Pos() != (substrates.Pos{X: 0, Y: 0}) {
+    t.Fatalf("bounded: agent walked off the edge to %v", ag.Pos())
   }
 }
 
@@ -77,7 +90,7 @@ func TestPredictiveDecide(t *testing.T) {
   evolver := func(src *substrates.Grid2d, rng *substrates.SplitMix64) *substrates.Grid2d {
     return src
   }
-  move := ag.predictiveDecide(g, evolver, true)
+  move := ag.predictiveDecide(g, evolver, true, ag.foresight)
   if move == substrates.North {
     t.Fatalf("agent chose to move into an obstacle")
   }
@@ -127,7 +140,7 @@ func TestPredictiveSequenceEast(t *testing.T) {
     },
   }
   original := asciiToGrid(originalSpecs[0])
-  move := ag.predictiveDecide(original, evolver, true)
+  move := ag.predictiveDecide(original, evolver, true, ag.foresight)
   if move != substrates.East {
     t.Fatalf("expected East, got %v", move)
   }
@@ -158,7 +171,7 @@ func TestPredictiveSequenceNorth(t *testing.T) {
     },
   }
   original := asciiToGrid(originalSpecs[0])
-  move := ag.predictiveDecide(original, evolver, true)
+  move := ag.predictiveDecide(original, evolver, true, ag.foresight)
   if move != substrates.North {
     t.Fatalf("expected North, got %v", move)
   }
@@ -197,7 +210,7 @@ func TestPredictiveSequenceRandom(t *testing.T) {
       rng:       substrates.NewSplitMix64(1),
     },
   }
-  move := ag.predictiveDecide(grids[0], evolver, false)
+  move := ag.predictiveDecide(grids[0], evolver, false, ag.foresight)
   if move != substrates.South {
     t.Fatalf("expected South, got %v", move)
   }
@@ -265,3 +278,79 @@ func TestRolloutBudgetSpecific(t *testing.T) {
   }()
   _ = rolloutBudgetMM(1, 0, false, 1, 8)
 }
+
+func TestFillUnknown(t *testing.T) {
+  g := substrates.NewGrid2d(6, 6)
+  rng := substrates.NewSplitMix64(5)
+  if fillUnknown(g, rng) != g {
+    t.Errorf("a known grid was copied")
+  }
+  g.Map(func(x, y, _ int) int {
+    return substrates.Live
+  })
+  g.SetXY(0, 0, substrates.Empty)
+  g.Forget(4, 4)
+  g.Forget(5, 5)
+  full := fillUnknown(g, rng)
+  if full.Partial() || !g.Partial() {
+    t.Fatalf("fillUnknown left unknown cells, or changed g")
+  }
+  if full.XY(0, 0) != substrates.Empty || full.XY(1, 0) != substrates.Live {
+    t.Errorf("fillUnknown changed known cells")
+  }
+}
+
+func TestPredictivePartialView(t *testing.T) {
+  // a still world seen through a 3x3 window: the wall to the north is
+  // in sight, and the agent must not walk into it
+  g := substrates.NewGrid2d(7, 7)
+  g.SetXY(3, 2, substrates.Live)
+  for y := 0; y < 7; y++ {
+    for x := 0; x < 7; x++ {
+      if x < 2 || x > 4 || y < 2 || y > 4 {
+        g.Forget(x, y)
+      }
+    }
+  }
+  still := func(g *substrates.Grid2d, _ *substrates.SplitMix64) *substrates.Grid2d {
+    if g.Partial() {
+      panic("evolver got unknown cells")
+    }
+    return g.Clone()
+  }
+  a := NewPredictiveAgent(1, substrates.Pos{X: 3, Y: 3}, 2,
+      substrates.NewSplitMix64(2))
+  if m := a.Decide(g, still, true); m == substrates.North {
+    t.Errorf("walked into a wall in sight")
+  }
+  mcts := NewMCTSAgent(2, substrates.Pos{X: 3, Y: 3}, 2, 20,
+      substrates.NewSplitMix64(2))
+  if m := mcts.Decide(g, still, true); m == substrates.North {
+    t.Errorf("mcts walked into a wall in sight")
+  }
+}
+
+// TestPredictiveTiesNotNorth checks that moves scored alike are drawn
+// from, rather than all given to the first of possibleMoves.
+func TestPredictiveTiesNotNorth(t *testing.T) {
+  g := substrates.NewGrid2d(5, 5)
+  still := alwaysSameEvolver(g)
+  seen := map[substrates.Move]bool{}
+  for seed := uint64(0); seed < 20; seed++ {
+    a := NewPredictiveAgent(1, substrates.Pos{X: 2, Y: 2}, 2,
+        substrates.NewSplitMix64(seed))
+    seen[a.Decide(g, still, true)] = true
+  }
+  if len(seen) < 3 {
+    t.Fatalf("an empty world yielded only moves %v", seen)
+  }
+  a := NewPredictiveAgent(1, substrates.Pos{X: 2, Y: 2}, 2,
+      substrates.NewSplitMix64(7))
+  b := NewPredictiveAgent(1, substrates.Pos{X: 2, Y: 2}, 2,
+      substrates.NewSplitMix64(7))
+  for i := 0; i < 5; i++ {
+    if m, n := a.Decide(g, still, true), b.Decide(g, still, true); m != n {
+      t.Fatalf("same seed, different moves %v and %v", m, n)
+    }
+  }
+}
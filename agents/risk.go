@@ -0,0 +1,156 @@
+package agents
+// Risk measures for collapsing roll‑out outcomes.
+//
+// Every reducer here ranks moves by a single statistic of their score
+// samples.  Scores are rewards, so risk lives in the lower tail:
+//   • CVaR_α      - mean of the worst α fraction of outcomes;
+//                   α = 1 is the mean, α → 0 the worst case
+//                   (Rockafellar & Uryasev, 2000).
+//   • entropic_θ  - −(1/θ)·log E[exp(−θX)]; θ → 0 is the mean,
+//                   θ → ∞ the worst case (Föllmer & Schied, 2002).
+//   • quantile_q  - the lower q‑quantile (value at risk);
+//                   q = 0 is the worst case, q = 0.5 the median.
+//
+// References
+// ----------
+// • Rockafellar, R. T. & Uryasev, S. “Optimization of Conditional
+//   Value‑at‑Risk.” *Journal of Risk*, 2000.
+// • Föllmer, H. & Schied, A. “Convex Measures of Risk and Trading
+//   Constraints.” *Finance and Stochastics*, 2002.
+import "fmt"
+import "math"
+import "sort"
+import "strconv"
+import "strings"
+import "oscarkilo.com/inteluni/substrates"
+
+// WorstCase ranks moves by their lowest outcome.
+func WorstCase() SurvivalReducer {
+  return worstCaseReducer
+}
+
+// MeanCase ranks moves by their mean outcome.
+func MeanCase() SurvivalReducer {
+  return meanCaseReducer
+}
+
+// CVaR ranks moves by the mean of their worst ⌈α·n⌉ outcomes.
+func CVaR(alpha float64) SurvivalReducer {
+  if !(alpha > 0 && alpha <= 1) {
+    panic("CVaR alpha must be in (0, 1]")
+  }
+  return func(
+      scoreByMove map[substrates.Move][]float64,
+  ) (substrates.Move, float64) {
+    return reduceBy(scoreByMove, func(scores []float64) float64 {
+      sorted := sortedCopy(scores)
+      k := int(math.Ceil(alpha * float64(len(sorted))))
+      if k < 1 {
+        k = 1
+      }
+      sum := 0.0
+      for _, score := range sorted[:k] {
+        sum += score
+      }
+      return sum / float64(k)
+    })
+  }
+}
+
+// EntropicRisk ranks moves by −(1/θ)·log E[exp(−θX)], θ > 0.
+func EntropicRisk(theta float64) SurvivalReducer {
+  if !(theta > 0) {
+    panic("entropic risk theta must be positive")
+  }
+  return func(
+      scoreByMove map[substrates.Move][]float64,
+  ) (substrates.Move, float64) {
+    return reduceBy(scoreByMove, func(scores []float64) float64 {
+      // shift by the minimum so the exponentials cannot overflow
+      low := scores[0]
+      for _, score := range scores[1:] {
+        low = math.Min(low, score)
+      }
+      sum := 0.0
+      for _, score := range scores {
+        sum += math.Exp(-theta * (score - low))
+      }
+      return low - math.Log(sum/float64(len(scores)))/theta
+    })
+  }
+}
+
+// Quantile ranks moves by their lower q‑quantile, 0 ≤ q ≤ 1.
+func Quantile(q float64) SurvivalReducer {
+  if !(q >= 0 && q <= 1) {
+    panic("quantile q must be in [0, 1]")
+  }
+  return func(
+      scoreByMove map[substrates.Move][]float64,
+  ) (substrates.Move, float64) {
+    return reduceBy(scoreByMove, func(scores []float64) float64 {
+      sorted := sortedCopy(scores)
+      i := int(math.Ceil(q*float64(len(sorted)))) - 1
+      if i < 0 {
+        i = 0
+      }
+      return sorted[i]
+    })
+  }
+}
+
+// ParseRiskMeasure builds a reducer from a name such as "worst",
+// "mean", "cvar:0.25", "entropic:2" or "quantile:0.1".
+func ParseRiskMeasure(s string) (SurvivalReducer, error) {
+  name, arg, hasArg := strings.Cut(s, ":")
+  var param float64
+  if hasArg {
+    var err error
+    param, err = strconv.ParseFloat(arg, 64)
+    if err != nil {
+      return nil, fmt.Errorf("risk measure %q: %v", s, err)
+    }
+  }
+  needArg := func(ok bool, want string) error {
+    if !hasArg {
+      return fmt.Errorf("risk measure %q: missing %s", s, want)
+    }
+    if !ok {
+      return fmt.Errorf("risk measure %q: %s out of range", s, want)
+    }
+    return nil
+  }
+  switch name {
+    case "worst", "mean":
+      if hasArg {
+        return nil, fmt.Errorf("risk measure %q takes no parameter", s)
+      }
+      if name == "worst" {
+        return WorstCase(), nil
+      }
+      return MeanCase(), nil
+    case "cvar":
+      if err := needArg(param > 0 && param <= 1, "alpha"); err != nil {
+        return nil, err
+      }
+      return CVaR(param), nil
+    case "entropic":
+      if err := needArg(param > 0, "theta"); err != nil {
+        return nil, err
+      }
+      return EntropicRisk(param), nil
+    case "quantile":
+      if err := needArg(param >= 0 && param <= 1, "q"); err != nil {
+        return nil, err
+      }
+      return Quantile(param), nil
+    default:
+      return nil, fmt.Errorf("unknown risk measure %q", s)
+  }
+}
+
+func sortedCopy(scores []float64) []float64 {
+  sorted := append([]float64(nil), scores...)
+  sort.Float64s(sorted)
+  return sorted
+}
@@ -0,0 +1,105 @@
+package agents
+import "math"
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+// Under a tail-sensitive measure North wins; under the mean East wins.
+var riskScores = map[substrates.Move][]float64{
+  substrates.North: {0.5, 0.5, 0.5, 0.5},
+  substrates.East:  {0.0, 1.0, 1.0, 1.0},
+}
+
+func TestCVaRReducer(t *testing.T) {
+  m, s := CVaR(0.25)(riskScores)
+  if m != substrates.North || math.Abs(s-0.5) > 1e-9 {
+    t.Fatalf("CVaR(0.25): expected North 0.5, got %v %v", m, s)
+  }
+  m, s = CVaR(1)(riskScores)
+  if m != substrates.East || math.Abs(s-0.75) > 1e-9 {
+    t.Fatalf("CVaR(1) should be the mean: got %v %v", m, s)
+  }
+  m, s = CVaR(0.5)(riskScores)
+  if m != substrates.North || math.Abs(s-0.5) > 1e-9 {
+    t.Fatalf("CVaR(0.5): expected North 0.5, got %v %v", m, s)
+  }
+}
+
+func TestEntropicRiskReducer(t *testing.T) {
+  m, s := EntropicRisk(1e-6)(riskScores)
+  if m != substrates.East || math.Abs(s-0.75) > 1e-5 {
+    t.Fatalf("small theta should approach the mean: got %v %v", m, s)
+  }
+  m, s = EntropicRisk(50)(riskScores)
+  if m != substrates.North || math.Abs(s-0.5) > 1e-9 {
+    t.Fatalf("large theta should approach the worst case: got %v %v", m, s)
+  }
+}
+
+func TestQuantileReducer(t *testing.T) {
+  m, s := Quantile(0)(riskScores)
+  if m != substrates.North || s != 0.5 {
+    t.Fatalf("Quantile(0) should be the worst case: got %v %v", m, s)
+  }
+  m, s = Quantile(0.5)(riskScores)
+  if m != substrates.East || s != 1.0 {
+    t.Fatalf("Quantile(0.5): expected East 1.0, got %v %v", m, s)
+  }
+}
+
+func TestReducerTieBreakIsStable(t *testing.T) {
+  tied := map[substrates.Move][]float64{
+    substrates.Stay:  {1},
+    substrates.West:  {1},
+    substrates.East:  {1},
+    substrates.South: {1},
+  }
+  for i := 0; i < 20; i++ {
+    if m, _ := worstCaseReducer(tied); m != substrates.South {
+      t.Fatalf("ties should go to the earliest move, got %v", m)
+    }
+  }
+}
+
+func TestParseRiskMeasure(t *testing.T) {
+  for _, ok := range []string{
+      "worst", "mean", "cvar:0.1", "entropic:2", "quantile:0"} {
+    if _, err := ParseRiskMeasure(ok); err != nil {
+      t.Errorf("%q: unexpected error %v", ok, err)
+    }
+  }
+  for _, bad := range []string{
+      "", "cvar", "cvar:0", "cvar:1.5", "entropic:-1", "quantile:x",
+      "worst:1", "var:0.1"} {
+    if _, err := ParseRiskMeasure(bad); err == nil {
+      t.Errorf("%q: expected error", bad)
+    }
+  }
+}
+
+func TestPredictiveUsesConfiguredReducer(t *testing.T) {
+  // North is open in only one of the two futures, East and Stay in
+  // both, so neither the worst case nor CVaR(0.5) should pick North.
+  grids := []*substrates.Grid2d{
+    asciiToGrid([]string{
+      "#_#",
+      "#__",
+      "###",
+    }),
+    asciiToGrid([]string{
+      "###",
+      "#__",
+      "###",
+    }),
+  }
+  for _, r := range []SurvivalReducer{WorstCase(), CVaR(0.5)} {
+    ag := NewPredictiveAgent(1, substrates.Pos{X: 1, Y: 1}, 1,
+        substrates.NewSplitMix64(2), WithReducer(r))
+    if ag.reducer == nil {
+      t.Fatalf("WithReducer did not set the reducer")
+    }
+    evolver := randomEvolver(grids)
+    if m := ag.Decide(grids[0], evolver, false); m == substrates.North {
+      t.Fatalf("risk-averse agent chose North")
+    }
+  }
+}
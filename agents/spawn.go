@@ -1,13 +1,107 @@
 package agents
 import "oscarkilo.com/inteluni/substrates"
 
+// Agent kinds, as named by Kind and in reports.
+const (
+  KindReactive   = "reactive"
+  KindPredictive = "predictive"
+  KindMCTS       = "mcts"
+  KindLearning   = "learning"
+  KindModel      = "model"
+)
+
+// Kind names the type of an agent.
+func Kind(ag Agent) string {
+  switch ag.(type) {
+    case *ReactiveAgent:
+      return KindReactive
+    case *PredictiveAgent:
+      return KindPredictive
+    case *MCTSAgent:
+      return KindMCTS
+    case *LearningAgent:
+      return KindLearning
+    case *ModelAgent:
+      return KindModel
+    default:
+      panic("unknown agent type")
+  }
+}
+
+// Population says how many agents of each type to spawn and how they
+// plan.  Foresight is the predictive depth and the MCTS horizon.
+type Population struct {
+  Reactive   int
+  Predictive int
+  MCTS       int
+  Foresight  int
+  MCTSBudget int              // simulations per MCTS decision
+  Risk       SurvivalReducer  // predictive risk measure, nil = worst case
+  Learning   int
+  QTable     *QTable          // shared by the learning agents
+  Learn      bool             // whether they update it
+  Model      int              // learn a world model, plan like Predictive
+  Budget     int              // predictive evolver calls per decision, 0: no cap
+}
+
+// NewAgent builds one agent of the named kind, configured by pop
+// (Foresight, MCTSBudget, Risk, QTable, Learn and Budget; the counts
+// are ignored).
+func NewAgent(
+    kind string,
+    id int,
+    pos substrates.Pos,
+    pop Population,
+    rng *substrates.SplitMix64,
+) Agent {
+  switch kind {
+    case KindReactive:
+      return NewReactiveAgent(id, pos, rng)
+    case KindPredictive:
+      return NewPredictiveAgent(id, pos, pop.Foresight, rng, pop.options()...)
+    case KindMCTS:
+      return NewMCTSAgent(id, pos, pop.Foresight, pop.MCTSBudget, rng)
+    case KindLearning:
+      return NewLearningAgent(id, pos, pop.QTable, pop.Learn, rng)
+    case KindModel:
+      return NewModelAgent(id, pos, pop.Foresight, rng, pop.options()...)
+    default:
+      panic("unknown agent type: " + kind)
+  }
+}
+
+// options configure the predictive and model agents.
+func (pop Population) options() []PredictiveOption {
+  var opts []PredictiveOption
+  if pop.Risk != nil {
+    opts = append(opts, WithReducer(pop.Risk))
+  }
+  if pop.Budget > 0 {
+    opts = append(opts, WithBudget(pop.Budget))
+  }
+  return opts
+}
+
 func Spawn(
     grid *substrates.Grid2d,
     numReactive, numPredictive, foresight int,
     rng *substrates.SplitMix64,
 ) []Agent {
+  return SpawnPopulation(grid, Population{
+    Reactive:   numReactive,
+    Predictive: numPredictive,
+    Foresight:  foresight,
+  }, rng)
+}
+
+func SpawnPopulation(
+    grid *substrates.Grid2d,
+    pop Population,
+    rng *substrates.SplitMix64,
+) []Agent {
 
-  total := numReactive + numPredictive
+  total := pop.Reactive + pop.Predictive + pop.MCTS + pop.Learning +
+      pop.Model
   totalCells := grid.W() * grid.H()
   if total > totalCells {
     panic("not enough cells to spawn all agents")
@@ -20,11 +114,20 @@ func Spawn(
 
   // Shuffle agent type spawn order
   types := make([]string, 0, total)
-  for i := 0; i < numReactive; i++ {
-    types = append(types, "reactive")
+  for i := 0; i < pop.Reactive; i++ {
+    types = append(types, KindReactive)
   }
-  for i := 0; i < numPredictive; i++ {
-    types = append(types, "predictive")
+  for i := 0; i < pop.Predictive; i++ {
+    types = append(types, KindPredictive)
+  }
+  for i := 0; i < pop.MCTS; i++ {
+    types = append(types, KindMCTS)
+  }
+  for i := 0; i < pop.Learning; i++ {
+    types = append(types, KindLearning)
+  }
+  for i := 0; i < pop.Model; i++ {
+    types = append(types, KindModel)
   }
   for i := total - 1; i > 0; i-- {
     j := rng.Intn(i+1)
@@ -34,8 +137,11 @@ func Spawn(
   nextID := 1
   attempts := 0
   maxAttempts := totalCells * 5
-  remainingReactive := numReactive  // used to double check correcntess
-  remainingPredictive := numPredictive
+  remainingReactive := pop.Reactive  // used to double check correcntess
+  remainingPredictive := pop.Predictive
+  remainingMCTS := pop.MCTS
+  remainingLearning := pop.Learning
+  remainingModel := pop.Model
 
   for len(result) < total {
     if attempts >= maxAttempts {
@@ -47,7 +153,7 @@ func Spawn(
     y := rng.Intn(grid.H())
     pos := substrates.Pos{X: x, Y: y}
 
-    if grid.XY(x, y) != 0 {
+    if substrates.Lethal(grid.XY(x, y)) {
       continue // obstacle
     }
     if _, taken := occupied[pos]; taken {
@@ -55,16 +161,19 @@ func Spawn(
     }
 
     agentType := types[len(result)]
-    var ag Agent
     agentRng  := rng.NewFromSelf()
-    if agentType == "reactive" {
-      ag = NewReactiveAgent(nextID, pos, agentRng)
-      remainingReactive--
-    } else if agentType == "predictive" {
-      ag = NewPredictiveAgent(nextID, pos, foresight, agentRng)
-      remainingPredictive--
-    } else {
-      panic("unknown agent type: " + agentType)
+    ag := NewAgent(agentType, nextID, pos, pop, agentRng)
+    switch agentType {
+      case KindReactive:
+        remainingReactive--
+      case KindPredictive:
+        remainingPredictive--
+      case KindMCTS:
+        remainingMCTS--
+      case KindLearning:
+        remainingLearning--
+      case KindModel:
+        remainingModel--
     }
 
     result = append(result, ag)
@@ -78,6 +187,15 @@ func Spawn(
   if remainingPredictive != 0 {
     panic("not all predictive agents spawned")
   }
+  if remainingMCTS != 0 {
+    panic("not all MCTS agents spawned")
+  }
+  if remainingLearning != 0 {
+    panic("not all learning agents spawned")
+  }
+  if remainingModel != 0 {
+    panic("not all model agents spawned")
+  }
 
   return result
 }
@@ -0,0 +1,109 @@
+package agents
+// A transposition table for predictive search.
+import "oscarkilo.com/inteluni/substrates"
+
+// transTable caches the values of search nodes: the score evaluate
+// returns for an agent at a position in a grid with some depth left to
+// look ahead.  Identical nodes are reached by different move orders
+// (moving north then east, or east then north), by standing still in a
+// still world, and in a deterministic universe from one tick to the
+// next, when the new search re-enters the subtrees the old one explored.
+//
+// The table has a fixed number of slots, each holding one node,
+// addressed by the low bits of the node's 64-bit key: the Zobrist hash
+// of the grid (Grid2d.Hash) mixed with the position and depth.  On a
+// clash the node with more depth left, whose value cost more to
+// compute, keeps the slot.  Clearing is O(1): entries carry the
+// generation they were stored in, and clear starts a new one.
+//
+// References
+// ----------
+// • Greenblatt, R. D., Eastlake, D. E. & Crocker, S. D. “The Greenblatt
+//   Chess Program.” *Proc. Fall Joint Computer Conference*, 1967.
+// • Breuker, D. M., Uiterwijk, J. W. H. M. & van den Herik, H. J.
+//   “Replacement Schemes for Transposition Tables.” *ICCA Journal*,
+//   1994.
+type transTable struct {
+  slots []ttEntry
+  gen   uint32
+  stats TableStats
+}
+
+type ttEntry struct {
+  key   uint64
+  gen   uint32   // 0: never stored
+  depth int32
+  score float64
+}
+
+// TableStats count what a transposition table was asked and did.
+type TableStats struct {
+  Probes    int  // lookups
+  Hits      int  // lookups that found the node
+  Stores    int  // nodes stored
+  Evictions int  // stores that replaced a live node of other key
+}
+
+// HitRate is Hits / Probes, 0 before any probe.
+func (s TableStats) HitRate() float64 {
+  if s.Probes == 0 {
+    return 0
+  }
+  return float64(s.Hits) / float64(s.Probes)
+}
+
+// DefaultTableSize is the number of slots of an agent's transposition
+// table, about 384 KiB.
+const DefaultTableSize = 1 << 14
+
+// newTransTable returns a table of size slots, rounded up to a power
+// of two.
+func newTransTable(size int) *transTable {
+  n := 1
+  for n < size {
+    n <<= 1
+  }
+  return &transTable{slots: make([]ttEntry, n), gen: 1}
+}
+
+// ttKey identifies a search node.
+func ttKey(g *substrates.Grid2d, pos substrates.Pos, depthLeft int) uint64 {
+  return g.Hash() ^
+      substrates.Mix64(uint64(pos.X)<<40 ^ uint64(pos.Y)<<16 ^
+          uint64(depthLeft) ^ 0x7FB5D329728EA185)
+}
+
+func (t *transTable) probe(key uint64) (float64, bool) {
+  t.stats.Probes++
+  e := &t.slots[key & uint64(len(t.slots)-1)]
+  if e.gen != t.gen || e.key != key {
+    return 0, false
+  }
+  t.stats.Hits++
+  return e.score, true
+}
+
+func (t *transTable) store(key uint64, depthLeft int, score float64) {
+  e := &t.slots[key & uint64(len(t.slots)-1)]
+  live := e.gen == t.gen
+  if live && e.key != key && int(e.depth) > depthLeft {
+    return  // keep the costlier node
+  }
+  if live && e.key != key {
+    t.stats.Evictions++
+  }
+  t.stats.Stores++
+  *e = ttEntry{key: key, gen: t.gen, depth: int32(depthLeft), score: score}
+}
+
+// clear forgets every node.
+func (t *transTable) clear() {
+  t.gen++
+  if t.gen == 0 {
+    // wrapped around: entries of the old generation 1 would come back
+    for i := range t.slots {
+      t.slots[i] = ttEntry{}
+    }
+    t.gen = 1
+  }
+}
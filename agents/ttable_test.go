@@ -0,0 +1,92 @@
+package agents
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+func TestTransTable(t *testing.T) {
+  tt := newTransTable(3)
+  if len(tt.slots) != 4 {
+    t.Fatalf("%d slots, want 4", len(tt.slots))
+  }
+  if _, ok := tt.probe(5); ok {
+    t.Fatalf("empty table hit")
+  }
+  tt.store(5, 2, 0.5)
+  if s, ok := tt.probe(5); !ok || s != 0.5 {
+    t.Fatalf("probe = %v, %v; want 0.5, true", s, ok)
+  }
+  // 9 shares the slot of 5; the deeper node keeps it
+  tt.store(9, 1, 0.25)
+  if _, ok := tt.probe(9); ok {
+    t.Fatalf("a shallower node evicted a deeper one")
+  }
+  tt.store(9, 3, 0.75)
+  if _, ok := tt.probe(5); ok {
+    t.Fatalf("a deeper node did not evict a shallower one")
+  }
+  if s, ok := tt.probe(9); !ok || s != 0.75 {
+    t.Fatalf("probe = %v, %v; want 0.75, true", s, ok)
+  }
+  tt.clear()
+  if _, ok := tt.probe(9); ok {
+    t.Fatalf("hit after clear")
+  }
+  tt.store(13, 0, 1)  // the slot is dead: no eviction
+  want := TableStats{Probes: 6, Hits: 2, Stores: 3, Evictions: 1}
+  if tt.stats != want {
+    t.Fatalf("stats %+v, want %+v", tt.stats, want)
+  }
+  if r := tt.stats.HitRate(); r != 2.0/6 {
+    t.Fatalf("hit rate %v", r)
+  }
+}
+
+func TestTTKey(t *testing.T) {
+  g := substrates.NewGrid2d(4, 4)
+  at := substrates.Pos{X: 1, Y: 2}
+  k := ttKey(g, at, 3)
+  if ttKey(g.Clone(), at, 3) != k {
+    t.Fatalf("identical nodes yielded different keys")
+  }
+  if ttKey(g, substrates.Pos{X: 2, Y: 1}, 3) == k {
+    t.Fatalf("different positions yielded identical keys")
+  }
+  if ttKey(g, at, 2) == k {
+    t.Fatalf("different depths yielded identical keys")
+  }
+  g.SetXY(0, 0, substrates.Live)
+  if ttKey(g, at, 3) == k {
+    t.Fatalf("different grids yielded identical keys")
+  }
+}
+
+// TestTablePersists checks that a deterministic agent carries its table
+// from one decision to the next, and that the table changes only how
+// much is simulated, not which moves are made.
+func TestTablePersists(t *testing.T) {
+  g := randomGrid(12, 12, substrates.NewSplitMix64(2))
+  life := func(g *substrates.Grid2d, _ *substrates.SplitMix64) *substrates.Grid2d {
+    return substrates.LifeStep(g, conwayBirth, conwaySurvive)
+  }
+  start := substrates.Pos{X: 6, Y: 6}
+  g.SetXY(start.X, start.Y, substrates.Empty)
+  big := NewPredictiveAgent(1, start, 4, substrates.NewSplitMix64(1))
+  tiny := NewPredictiveAgent(2, start, 4, substrates.NewSplitMix64(1),
+      WithTableSize(1))
+  for tick := 0; tick < 5; tick++ {
+    hits := big.TableStats().Hits
+    m := big.Decide(g, life, true)
+    if n := tiny.Decide(g, life, true); n != m {
+      t.Fatalf("tick %d: table size changed the move, %v vs %v", tick, m, n)
+    }
+    if tick > 0 && big.TableStats().Hits == hits {
+      t.Errorf("tick %d: no hits in a persistent table", tick)
+    }
+    g = life(g, nil)
+    big.Apply(m, g)
+    tiny.Apply(m, g)
+  }
+  if big.EvolverCalls() >= tiny.EvolverCalls() {
+    t.Errorf("%d evolver calls with a table, %d without",
+        big.EvolverCalls(), tiny.EvolverCalls())
+  }
+}
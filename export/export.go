@@ -0,0 +1,227 @@
+// Package export renders simulation frames to PNG images and animated
+// GIFs, for figures and for looking at strange runs outside a terminal.
+//
+// Frames are view.Frame values: a grid with agents overlaid, from a
+// recorded episode (view.EpisodeFrames) or a live run (view.Live).
+// Each cell becomes a CellSize×CellSize square; an agent is a smaller
+// square inside its cell in its kind's color, and an agent at the tick
+// it died is a cross.
+package export
+import "fmt"
+import "image"
+import "image/color"
+import "image/gif"
+import "image/png"
+import "io"
+import "os"
+import "path/filepath"
+import "sort"
+import "time"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/agents"
+import "oscarkilo.com/inteluni/view"
+
+// Palette colors cells by state and agents by kind.
+type Palette struct {
+  Empty  color.RGBA
+  Live   color.RGBA
+  Decay  []color.RGBA  // refractory states 2, 3, ...; later states reuse the last
+  Agents map[string]color.RGBA  // by agents.Kind
+  Dead   color.RGBA
+}
+
+// DefaultPalette draws dark cells on white, decay in fading greys and
+// agents in the colors the terminal viewer uses.
+func DefaultPalette() Palette {
+  return Palette{
+    Empty: color.RGBA{0xff, 0xff, 0xff, 0xff},
+    Live:  color.RGBA{0x20, 0x20, 0x20, 0xff},
+    Decay: []color.RGBA{
+      {0x70, 0x70, 0x70, 0xff},
+      {0xa0, 0xa0, 0xa0, 0xff},
+      {0xd0, 0xd0, 0xd0, 0xff},
+    },
+    Agents: map[string]color.RGBA{
+      agents.KindReactive:   {0xe0, 0xa0, 0x00, 0xff},
+      agents.KindPredictive: {0x00, 0x90, 0xc0, 0xff},
+      agents.KindMCTS:       {0xb0, 0x30, 0xb0, 0xff},
+      agents.KindLearning:   {0x20, 0xa0, 0x40, 0xff},
+      agents.KindModel:      {0x30, 0x50, 0xe0, 0xff},
+    },
+    Dead: color.RGBA{0xd0, 0x10, 0x10, 0xff},
+  }
+}
+
+// Options control rendering.  The zero value is usable: it means
+// DefaultOptions.
+type Options struct {
+  CellSize int            // pixels per cell side, default 8
+  Palette  *Palette       // default DefaultPalette()
+  Delay    time.Duration  // GIF time per frame, default 200ms
+}
+
+// DefaultOptions are used for any zero fields of Options.
+var DefaultOptions = Options{
+  CellSize: 8,
+  Delay:    200 * time.Millisecond,
+}
+
+func (o Options) withDefaults() Options {
+  if o.CellSize <= 0 {
+    o.CellSize = DefaultOptions.CellSize
+  }
+  if o.Palette == nil {
+    p := DefaultPalette()
+    o.Palette = &p
+  }
+  if o.Delay <= 0 {
+    o.Delay = DefaultOptions.Delay
+  }
+  return o
+}
+
+// colors is a Palette flattened into the indexed palette of an image.
+type colors struct {
+  palette color.Palette
+  decay   []uint8
+  agents  map[string]uint8
+  dead    uint8
+}
+
+const (
+  emptyIndex = 0
+  liveIndex  = 1
+)
+
+func (p *Palette) indexed() colors {
+  c := colors{
+    palette: color.Palette{p.Empty, p.Live},
+    agents:  map[string]uint8{},
+  }
+  add := func(rgba color.RGBA) uint8 {
+    c.palette = append(c.palette, rgba)
+    return uint8(len(c.palette) - 1)
+  }
+  for _, d := range p.Decay {
+    c.decay = append(c.decay, add(d))
+  }
+  if len(c.decay) == 0 {
+    c.decay = []uint8{liveIndex}
+  }
+  c.dead = add(p.Dead)
+  // sorted so that the palette does not depend on map order
+  kinds := make([]string, 0, len(p.Agents))
+  for kind := range p.Agents {
+    kinds = append(kinds, kind)
+  }
+  sort.Strings(kinds)
+  for _, kind := range kinds {
+    c.agents[kind] = add(p.Agents[kind])
+  }
+  if len(c.palette) > 256 {
+    panic("palette has more than 256 colors")
+  }
+  return c
+}
+
+func (c *colors) cell(val int) uint8 {
+  switch val {
+    case substrates.Empty:
+      return emptyIndex
+    case substrates.Live:
+      return liveIndex
+    default:
+      return c.decay[min(val-2, len(c.decay)-1)]
+  }
+}
+
+// Image renders one frame.
+func Image(f view.Frame, opts Options) *image.Paletted {
+  opts = opts.withDefaults()
+  c := opts.Palette.indexed()
+  return render(f, opts.CellSize, &c)
+}
+
+func render(f view.Frame, size int, c *colors) *image.Paletted {
+  g := f.Grid
+  img := image.NewPaletted(
+      image.Rect(0, 0, g.W()*size, g.H()*size), c.palette)
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      fill(img, x*size, y*size, size, c.cell(g.XY(x, y)))
+    }
+  }
+  inset := size / 4
+  for _, m := range f.Agents {
+    x0, y0 := m.Pos.X*size, m.Pos.Y*size
+    if m.Dead {
+      for i := 0; i < size; i++ {
+        img.SetColorIndex(x0+i, y0+i, c.dead)
+        img.SetColorIndex(x0+size-1-i, y0+i, c.dead)
+      }
+      continue
+    }
+    idx, ok := c.agents[m.Kind]
+    if !ok {
+      idx = c.dead
+    }
+    fill(img, x0+inset, y0+inset, size-2*inset, idx)
+  }
+  return img
+}
+
+func fill(img *image.Paletted, x0, y0, size int, idx uint8) {
+  for y := y0; y < y0+size; y++ {
+    for x := x0; x < x0+size; x++ {
+      img.SetColorIndex(x, y, idx)
+    }
+  }
+}
+
+// WritePNG writes one frame as a PNG.
+func WritePNG(w io.Writer, f view.Frame, opts Options) error {
+  return png.Encode(w, Image(f, opts))
+}
+
+// WritePNGs writes each frame to dir as prefix_0000.png, prefix_0001.png
+// and so on, numbered by tick, and returns the file names.
+func WritePNGs(
+    dir, prefix string,
+    frames []view.Frame,
+    opts Options,
+) ([]string, error) {
+  var paths []string
+  for _, f := range frames {
+    path := filepath.Join(dir, fmt.Sprintf("%s_%04d.png", prefix, f.T))
+    out, err := os.Create(path)
+    if err != nil {
+      return paths, err
+    }
+    err = WritePNG(out, f, opts)
+    if cerr := out.Close(); err == nil {
+      err = cerr
+    }
+    if err != nil {
+      return paths, err
+    }
+    paths = append(paths, path)
+  }
+  return paths, nil
+}
+
+// WriteGIF writes the frames as a looping animated GIF.
+func WriteGIF(w io.Writer, frames []view.Frame, opts Options) error {
+  if len(frames) == 0 {
+    return fmt.Errorf("no frames to animate")
+  }
+  opts = opts.withDefaults()
+  c := opts.Palette.indexed()
+  // GIF delays are in hundredths of a second
+  delay := int(opts.Delay / (10 * time.Millisecond))
+  anim := &gif.GIF{}
+  for _, f := range frames {
+    anim.Image = append(anim.Image, render(f, opts.CellSize, &c))
+    anim.Delay = append(anim.Delay, delay)
+  }
+  return gif.EncodeAll(w, anim)
+}
@@ -0,0 +1,125 @@
+package export
+import "bytes"
+import "image/color"
+import "image/gif"
+import "image/png"
+import "os"
+import "path/filepath"
+import "testing"
+import "time"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/agents"
+import "oscarkilo.com/inteluni/view"
+
+func testFrame(t int) view.Frame {
+  g := substrates.NewGrid2d(3, 2)
+  g.SetXY(1, 0, substrates.Live)
+  g.SetXY(2, 0, 2)
+  g.SetXY(2, 1, 9)
+  return view.Frame{T: t, Grid: g, Agents: []view.Mark{
+    {ID: 1, Kind: agents.KindPredictive, Pos: substrates.Pos{X: 0, Y: 1}},
+    {ID: 2, Kind: agents.KindReactive, Pos: substrates.Pos{X: 1, Y: 0},
+        Dead: true},
+  }}
+}
+
+func rgba(c color.Color) color.RGBA {
+  r, g, b, a := c.RGBA()
+  return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+func TestImage(t *testing.T) {
+  p := DefaultPalette()
+  img := Image(testFrame(0), Options{CellSize: 8})
+  if b := img.Bounds(); b.Dx() != 24 || b.Dy() != 16 {
+    t.Fatalf("bounds %v", b)
+  }
+  for _, c := range []struct {
+    x, y int
+    want color.RGBA
+  }{
+    {0, 0, p.Empty},              // empty cell
+    {9, 1, p.Dead},               // dead agent's cross on a live cell
+    {12, 1, p.Live},              // off the cross
+    {20, 4, p.Decay[0]},          // state 2
+    {20, 12, p.Decay[len(p.Decay)-1]},  // state 9 reuses the last shade
+    {4, 12, p.Agents[agents.KindPredictive]},
+    {0, 8, p.Empty},              // agent's cell border
+  } {
+    if got := rgba(img.At(c.x, c.y)); got != c.want {
+      t.Errorf("pixel (%d,%d) = %v, want %v", c.x, c.y, got, c.want)
+    }
+  }
+}
+
+func TestWriteGIF(t *testing.T) {
+  var buf bytes.Buffer
+  frames := []view.Frame{testFrame(0), testFrame(1), testFrame(2)}
+  err := WriteGIF(&buf, frames, Options{CellSize: 4, Delay: time.Second})
+  if err != nil {
+    t.Fatal(err)
+  }
+  anim, err := gif.DecodeAll(&buf)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(anim.Image) != 3 || anim.Delay[0] != 100 {
+    t.Errorf("%d frames with delay %v", len(anim.Image), anim.Delay)
+  }
+  if b := anim.Image[0].Bounds(); b.Dx() != 12 || b.Dy() != 8 {
+    t.Errorf("bounds %v", b)
+  }
+  if err := WriteGIF(&buf, nil, Options{}); err == nil {
+    t.Errorf("animated no frames")
+  }
+}
+
+func TestWritePNGs(t *testing.T) {
+  dir := t.TempDir()
+  paths, err := WritePNGs(dir, "ep",
+      []view.Frame{testFrame(0), testFrame(1)}, Options{})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(paths) != 2 || filepath.Base(paths[1]) != "ep_0001.png" {
+    t.Fatalf("wrote %v", paths)
+  }
+  f, err := os.Open(paths[1])
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer f.Close()
+  img, err := png.Decode(f)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if b := img.Bounds(); b.Dx() != 3*DefaultOptions.CellSize {
+    t.Errorf("bounds %v", b)
+  }
+}
+
+func TestLoadPalette(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "p.json")
+  err := os.WriteFile(path, []byte(`{
+    "live": "#ff0000", "decay": ["#00ff00"], "agents": {"mcts": "#0000ff"}
+  }`), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  p, err := LoadPalette(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  def := DefaultPalette()
+  if p.Live != (color.RGBA{0xff, 0, 0, 0xff}) || p.Empty != def.Empty ||
+      len(p.Decay) != 1 || p.Agents[agents.KindMCTS].B != 0xff ||
+      p.Agents[agents.KindReactive] != def.Agents[agents.KindReactive] {
+    t.Errorf("loaded %+v", p)
+  }
+  for _, bad := range []string{`{"live": "red"}`, `{"colour": "#000000"}`} {
+    os.WriteFile(path, []byte(bad), 0644)
+    if _, err := LoadPalette(path); err == nil {
+      t.Errorf("accepted %s", bad)
+    }
+  }
+}
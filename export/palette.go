@@ -0,0 +1,78 @@
+package export
+import "encoding/json"
+import "fmt"
+import "image/color"
+import "os"
+import "strconv"
+import "strings"
+
+// LoadPalette reads a palette from a JSON file of "#rrggbb" colors,
+//
+//   {
+//     "empty": "#ffffff", "live": "#202020",
+//     "decay": ["#707070", "#a0a0a0"],
+//     "agents": {"reactive": "#e0a000", "predictive": "#0090c0"},
+//     "dead": "#d01010"
+//   }
+//
+// Colors left out keep their DefaultPalette values.
+func LoadPalette(path string) (*Palette, error) {
+  data, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  var spec struct {
+    Empty  string            `json:"empty"`
+    Live   string            `json:"live"`
+    Decay  []string          `json:"decay"`
+    Agents map[string]string `json:"agents"`
+    Dead   string            `json:"dead"`
+  }
+  if err := strictUnmarshal(data, &spec); err != nil {
+    return nil, fmt.Errorf("%s: %v", path, err)
+  }
+  p := DefaultPalette()
+  set := func(dst *color.RGBA, hex string) {
+    if hex == "" || err != nil {
+      return
+    }
+    *dst, err = ParseColor(hex)
+  }
+  set(&p.Empty, spec.Empty)
+  set(&p.Live, spec.Live)
+  set(&p.Dead, spec.Dead)
+  if spec.Decay != nil {
+    p.Decay = make([]color.RGBA, len(spec.Decay))
+    for i, hex := range spec.Decay {
+      set(&p.Decay[i], hex)
+    }
+  }
+  for kind, hex := range spec.Agents {
+    var c color.RGBA
+    set(&c, hex)
+    p.Agents[kind] = c
+  }
+  if err != nil {
+    return nil, fmt.Errorf("%s: %v", path, err)
+  }
+  return &p, nil
+}
+
+// ParseColor parses "#rrggbb".
+func ParseColor(s string) (color.RGBA, error) {
+  hex, ok := strings.CutPrefix(s, "#")
+  if !ok || len(hex) != 6 {
+    return color.RGBA{}, fmt.Errorf("color %q: want #rrggbb", s)
+  }
+  v, err := strconv.ParseUint(hex, 16, 32)
+  if err != nil {
+    return color.RGBA{}, fmt.Errorf("color %q: want #rrggbb", s)
+  }
+  return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+func strictUnmarshal(data []byte, v interface{}) error {
+  dec := json.NewDecoder(strings.NewReader(string(data)))
+  dec.DisallowUnknownFields()
+  return dec.Decode(v)
+}
@@ -0,0 +1,270 @@
+package metrics
+import "bytes"
+import "compress/flate"
+import "compress/lzw"
+import "fmt"
+import "io"
+import "math"
+import "strings"
+import "oscarkilo.com/inteluni/substrates"
+
+// ---------- Complexity estimators ----------
+// KolmogorovProxy is one definition of the complexity of an episode;
+// the estimators here are others, so that conclusions drawn from K can
+// be checked against more than one.  All read the episode as a
+// space-time volume of cell states, frame by frame, row by row.
+//
+//   • gzip, flate, lzw  - compressed / raw size.  flate is gzip's DEFLATE
+//                         at maximum level without gzip's header, which
+//                         dominates on small episodes.
+//   • lz76              - Lempel–Ziv (1976) phrase count, normalised so
+//                         that an i.i.d. uniform sequence scores ≈ 1.
+//   • block:WxHxD       - Shannon entropy of W×H×D space-time patches,
+//                         in bits per cell.
+//   • rate:WxHxD        - entropy of a patch's last frame given its
+//                         first D-1: bits per cell per tick, an
+//                         estimate of the entropy rate.
+//
+// Patches wrap around the grid edges whatever its topology.
+//
+// References
+// ----------
+// • Lempel, A. & Ziv, J. “On the Complexity of Finite Sequences.”
+//   *IEEE Trans. Inf. Theory*, 1976.
+// • Kaspar, F. & Schuster, H. G. “Easily Calculable Measure for the
+//   Complexity of Spatiotemporal Patterns.” *Phys. Rev. A*, 1987.
+// • Crutchfield, J. P. & Feldman, D. P. “Regularities Unseen, Randomness
+//   Observed: Levels of Entropy Convergence.” *Chaos*, 2003.
+
+// ComplexityEstimator scores an episode's frames.
+type ComplexityEstimator interface {
+  Name() string  // as accepted by ParseComplexityEstimator
+  Estimate(frames []*substrates.Grid2d) float64
+}
+
+// ParseComplexityEstimator builds an estimator from its name: "gzip",
+// "flate", "lzw", "lz76", "block:WxHxD" or "rate:WxHxD".
+func ParseComplexityEstimator(s string) (ComplexityEstimator, error) {
+  name, arg, hasArg := strings.Cut(s, ":")
+  switch name {
+    case "gzip", "flate", "lzw", "lz76":
+      if hasArg {
+        return nil, fmt.Errorf("estimator %q takes no parameter", s)
+      }
+  }
+  switch name {
+    case "gzip":
+      return GzipRatio{}, nil
+    case "flate":
+      return FlateRatio{}, nil
+    case "lzw":
+      return LZWRatio{}, nil
+    case "lz76":
+      return LZ76{}, nil
+    case "block", "rate":
+      var p Patch
+      _, err := fmt.Sscanf(arg, "%dx%dx%d", &p.W, &p.H, &p.D)
+      if err != nil || p.String() != arg {
+        return nil, fmt.Errorf("estimator %q: want %s:WxHxD", s, name)
+      }
+      if p.W <= 0 || p.H <= 0 || p.D <= 0 || (name == "rate" && p.D < 2) {
+        return nil, fmt.Errorf("estimator %q: patch too small", s)
+      }
+      if name == "block" {
+        return BlockEntropy{p}, nil
+      }
+      return EntropyRate{p}, nil
+    default:
+      return nil, fmt.Errorf("unknown complexity estimator %q", s)
+  }
+}
+
+// GzipRatio is KolmogorovProxy.
+type GzipRatio struct{}
+
+func (GzipRatio) Name() string { return "gzip" }
+
+func (GzipRatio) Estimate(frames []*substrates.Grid2d) float64 {
+  return KolmogorovProxy(frames)
+}
+
+// FlateRatio is the raw DEFLATE ratio at maximum compression.
+type FlateRatio struct{}
+
+func (FlateRatio) Name() string { return "flate" }
+
+func (FlateRatio) Estimate(frames []*substrates.Grid2d) float64 {
+  return compressionRatio(frames, func(w io.Writer) io.WriteCloser {
+    zw, err := flate.NewWriter(w, flate.BestCompression)
+    if err != nil {
+      panic(err)
+    }
+    return zw
+  })
+}
+
+// LZWRatio is the compress/lzw ratio, with 8-bit literals.
+type LZWRatio struct{}
+
+func (LZWRatio) Name() string { return "lzw" }
+
+func (LZWRatio) Estimate(frames []*substrates.Grid2d) float64 {
+  return compressionRatio(frames, func(w io.Writer) io.WriteCloser {
+    return lzw.NewWriter(w, lzw.LSB, 8)
+  })
+}
+
+func compressionRatio(
+    frames []*substrates.Grid2d,
+    compressor func(io.Writer) io.WriteCloser,
+) float64 {
+  raw := spaceTime(frames)
+  var cmp bytes.Buffer
+  zw := compressor(&cmp)
+  if _, err := zw.Write(raw); err != nil {
+    panic("compressionRatio: compression failed")
+  }
+  if err := zw.Close(); err != nil {
+    panic("compressionRatio: compression failed")
+  }
+  return float64(cmp.Len()) / float64(len(raw))
+}
+
+// LZ76 is the normalised Lempel–Ziv complexity c·log_k(n)/n of the
+// n cell states, k the number of distinct states (at least 2).
+type LZ76 struct{}
+
+func (LZ76) Name() string { return "lz76" }
+
+func (LZ76) Estimate(frames []*substrates.Grid2d) float64 {
+  s := spaceTime(frames)
+  seen := map[byte]bool{}
+  for _, v := range s {
+    seen[v] = true
+  }
+  k := math.Max(2, float64(len(seen)))
+  n := float64(len(s))
+  return float64(lz76Phrases(s)) * math.Log(n) / math.Log(k) / n
+}
+
+// lz76Phrases counts the phrases of the Lempel–Ziv 1976 parsing, by
+// the algorithm of Kaspar & Schuster.
+func lz76Phrases(s []byte) int {
+  n := len(s)
+  if n < 2 {
+    return n
+  }
+  c, l, i, k, kmax := 1, 1, 0, 1, 1
+  for {
+    if s[i+k-1] == s[l+k-1] {
+      k++
+      if l+k > n {
+        c++
+        return c
+      }
+      continue
+    }
+    kmax = max(kmax, k)
+    i++
+    if i == l {
+      // no earlier copy: a new phrase ends here
+      c++
+      l += kmax
+      if l+1 > n {
+        return c
+      }
+      i, k, kmax = 0, 1, 1
+    } else {
+      k = 1
+    }
+  }
+}
+
+// Patch is a W×H×D space-time window: W×H cells over D frames.
+type Patch struct {
+  W, H, D int
+}
+
+func (p Patch) String() string {
+  return fmt.Sprintf("%dx%dx%d", p.W, p.H, p.D)
+}
+
+// BlockEntropy is the Shannon entropy of Patch-shaped blocks, in bits
+// per cell.
+type BlockEntropy struct {
+  Patch
+}
+
+func (b BlockEntropy) Name() string { return "block:" + b.Patch.String() }
+
+func (b BlockEntropy) Estimate(frames []*substrates.Grid2d) float64 {
+  h := patchEntropy(frames, b.Patch, b.Patch.D)
+  return h / float64(b.W*b.H*b.D)
+}
+
+// EntropyRate is H(W×H×D) − H(W×H×(D-1)) per W×H cells: the
+// uncertainty left in a patch's next frame after seeing D-1 frames.
+type EntropyRate struct {
+  Patch
+}
+
+func (r EntropyRate) Name() string { return "rate:" + r.Patch.String() }
+
+func (r EntropyRate) Estimate(frames []*substrates.Grid2d) float64 {
+  // count both block lengths over the same positions, so that their
+  // difference is a conditional entropy
+  h := patchEntropy(frames, r.Patch, r.D) -
+      patchEntropy(frames, r.Patch, r.D-1)
+  return math.Max(0, h) / float64(r.W*r.H)
+}
+
+// patchEntropy is the entropy in bits of the first depth frames of the
+// W×H×D patches at every position.  It is 0 if fewer than D frames.
+func patchEntropy(frames []*substrates.Grid2d, p Patch, depth int) float64 {
+  if len(frames) < p.D {
+    return 0
+  }
+  w, h := frames[0].W(), frames[0].H()
+  counts := map[string]int{}
+  key := make([]byte, 0, p.W*p.H*depth)
+  total := 0
+  for t := 0; t+p.D <= len(frames); t++ {
+    for y := 0; y < h; y++ {
+      for x := 0; x < w; x++ {
+        key = key[:0]
+        for dt := 0; dt < depth; dt++ {
+          g := frames[t+dt]
+          for dy := 0; dy < p.H; dy++ {
+            for dx := 0; dx < p.W; dx++ {
+              key = append(key, byte(g.XY((x+dx)%w, (y+dy)%h)))
+            }
+          }
+        }
+        counts[string(key)]++
+        total++
+      }
+    }
+  }
+  entropy := 0.0
+  for _, c := range counts {
+    q := float64(c) / float64(total)
+    entropy -= q * math.Log2(q)
+  }
+  return entropy
+}
+
+// spaceTime lays the frames out one byte per cell.
+func spaceTime(frames []*substrates.Grid2d) []byte {
+  if len(frames) == 0 {
+    panic("complexity: no grids provided")
+  }
+  raw := make([]byte, 0, len(frames)*frames[0].W()*frames[0].H())
+  for _, g := range frames {
+    for y := 0; y < g.H(); y++ {
+      for x := 0; x < g.W(); x++ {
+        raw = append(raw, byte(g.XY(x, y)))
+      }
+    }
+  }
+  return raw
+}
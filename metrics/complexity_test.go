@@ -0,0 +1,92 @@
+package metrics
+import "math"
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+func framesOf(n, w, h int, cell func(t, x, y int) int) []*substrates.Grid2d {
+  frames := make([]*substrates.Grid2d, n)
+  for t := range frames {
+    frames[t] = substrates.NewGrid2d(w, h)
+    frames[t].Map(func(x, y, _ int) int { return cell(t, x, y) })
+  }
+  return frames
+}
+
+func TestLZ76Phrases(t *testing.T) {
+  // 0·001·10·100·1000·101, the example of Kaspar & Schuster
+  s := []byte{0,0,0,1,1,0,1,0,0,1,0,0,0,1,0,1}
+  if got := lz76Phrases(s); got != 6 {
+    t.Errorf("got %d phrases, want 6", got)
+  }
+  if got := lz76Phrases([]byte{1, 1, 1, 1, 1, 1}); got != 2 {
+    t.Errorf("constant sequence: %d phrases, want 2", got)
+  }
+}
+
+func TestEstimatorsOrderNoiseAboveOrder(t *testing.T) {
+  rng := substrates.NewSplitMix64(5)
+  still := framesOf(20, 16, 16, func(_, x, y int) int {
+    return (x / 4 + y / 4) % 2
+  })
+  noise := framesOf(20, 16, 16, func(_, _, _ int) int {
+    return rng.Intn(2)
+  })
+  for _, name := range []string{
+      "gzip", "flate", "lzw", "lz76", "block:2x2x2", "rate:2x2x2"} {
+    est, err := ParseComplexityEstimator(name)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if est.Name() != name {
+      t.Errorf("%s named %s", name, est.Name())
+    }
+    lo, hi := est.Estimate(still), est.Estimate(noise)
+    if !(lo < hi) {
+      t.Errorf("%s: still %v not below noise %v", name, lo, hi)
+    }
+  }
+}
+
+func TestEntropyEstimates(t *testing.T) {
+  rng := substrates.NewSplitMix64(9)
+  noise := framesOf(40, 32, 32, func(_, _, _ int) int {
+    return rng.Intn(2)
+  })
+  // 40×32×32 samples of 2^4 patterns: close to 1 bit per cell
+  if h := (BlockEntropy{Patch{2, 2, 1}}).Estimate(noise); math.Abs(h-1) > 0.01 {
+    t.Errorf("block entropy of noise %v, want ≈ 1", h)
+  }
+  if h := (EntropyRate{Patch{1, 1, 2}}).Estimate(noise); math.Abs(h-1) > 0.01 {
+    t.Errorf("entropy rate of noise %v, want ≈ 1", h)
+  }
+  // a glider-free still life: spatial entropy but no new information
+  still := framesOf(10, 8, 8, func(_, x, y int) int { return (x*y) % 2 })
+  if h := (BlockEntropy{Patch{2, 2, 1}}).Estimate(still); h <= 0 {
+    t.Errorf("block entropy of a pattern %v", h)
+  }
+  if h := (EntropyRate{Patch{2, 2, 3}}).Estimate(still); h != 0 {
+    t.Errorf("entropy rate of a still pattern %v, want 0", h)
+  }
+  // the empty world has no entropy at all
+  empty := framesOf(5, 8, 8, func(_, _, _ int) int { return 0 })
+  if h := (BlockEntropy{Patch{3, 3, 2}}).Estimate(empty); h != 0 {
+    t.Errorf("block entropy of empty frames %v", h)
+  }
+}
+
+func TestParseComplexityEstimatorRejects(t *testing.T) {
+  for _, bad := range []string{
+      "zstd", "gzip:9", "block", "block:2x2", "block:0x2x2", "rate:2x2x1",
+      "block:2x2x2x", "rate:axbxc"} {
+    if _, err := ParseComplexityEstimator(bad); err == nil {
+      t.Errorf("accepted %q", bad)
+    }
+  }
+}
+
+func TestGzipRatioIsKolmogorovProxy(t *testing.T) {
+  frames := framesOf(3, 5, 4, func(t, x, y int) int { return (t+x+y) % 2 })
+  if (GzipRatio{}).Estimate(frames) != KolmogorovProxy(frames) {
+    t.Errorf("gzip estimator differs from KolmogorovProxy")
+  }
+}
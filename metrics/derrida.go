@@ -0,0 +1,270 @@
+package metrics
+import "fmt"
+import "math"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/universes"
+
+// ---------- Derrida map and damage spreading ----------
+// Two configurations at normalised Hamming distance ρ0 are evolved one
+// step; the Derrida map is the mean distance ρ1 after it.  Its slope at
+// the origin tells how small damage grows in one step: below 1 it
+// heals (ordered), above 1 it spreads (chaotic), near 1 the universe is
+// critical.  Damage spreading follows a small perturbation for many
+// steps instead; the damage it settles at is 0 in ordered universes.
+//
+// Base configurations are taken from the universe's own trajectory,
+// starting at its current grid, so that they are typical of it rather
+// than of random soups.
+//
+// References
+// ----------
+// • Derrida, B. & Pomeau, Y. “Random Networks of Automata: A Simple
+//   Annealed Approximation.” *Europhys. Lett.*, 1986.
+// • Stauffer, D. “On Forcing Functions in Kauffman's Random Boolean
+//   Networks.” *J. Stat. Phys.*, 1987.
+
+// DynamicsClass is where a universe sits between order and chaos.
+type DynamicsClass int
+
+const (
+  Ordered DynamicsClass = iota
+  Critical
+  Chaotic
+)
+
+var dynamicsClassNames = []string{"ordered", "critical", "chaotic"}
+
+func (c DynamicsClass) String() string {
+  if c < 0 || int(c) >= len(dynamicsClassNames) {
+    return fmt.Sprintf("DynamicsClass(%d)", int(c))
+  }
+  return dynamicsClassNames[c]
+}
+
+// ParseDynamicsClass is the inverse of String.
+func ParseDynamicsClass(s string) (DynamicsClass, error) {
+  for i, name := range dynamicsClassNames {
+    if name == s {
+      return DynamicsClass(i), nil
+    }
+  }
+  return Ordered, fmt.Errorf("unknown dynamics class %q", s)
+}
+
+// DerridaOptions configure Derrida.  Zero fields take their values
+// from DefaultDerridaOptions.
+type DerridaOptions struct {
+  // Distances are the initial distances ρ0 of the Derrida curve, as
+  // fractions of the grid; each flips at least one cell.
+  Distances   []float64 `json:"distances,omitempty"`
+  // Samples is the number of pairs per distance, and of damage
+  // spreading runs.
+  Samples     int       `json:"samples,omitempty"`
+  // SlopeRange: the slope is fitted through the origin to points with
+  // ρ0 up to this.
+  SlopeRange  float64   `json:"slope_range,omitempty"`
+  // SpreadInit is the initial damage of damage spreading runs, which
+  // last SpreadSteps; the steady state is the mean over the last
+  // SpreadTail of them.
+  SpreadInit  float64   `json:"spread_init,omitempty"`
+  SpreadSteps int       `json:"spread_steps,omitempty"`
+  SpreadTail  int       `json:"spread_tail,omitempty"`
+  // Tolerance is how far the slope may be from 1 for a critical
+  // universe, and DamageFloor the steady damage counted as healed.
+  Tolerance   float64   `json:"tolerance,omitempty"`
+  DamageFloor float64   `json:"damage_floor,omitempty"`
+  // IndependentNoise gives the two copies separate random streams.
+  // By default they share one, so that in a noisy universe only the
+  // initial damage tells them apart.
+  IndependentNoise bool `json:"independent_noise,omitempty"`
+}
+
+// DefaultDerridaOptions are used for zero fields of DerridaOptions.
+var DefaultDerridaOptions = DerridaOptions{
+  Distances:   []float64{0.01, 0.02, 0.05, 0.1, 0.2, 0.3, 0.4, 0.5},
+  Samples:     20,
+  SlopeRange:  0.1,
+  SpreadInit:  0.05,
+  SpreadSteps: 100,
+  SpreadTail:  20,
+  Tolerance:   0.1,
+  DamageFloor: 0.005,
+}
+
+func (o DerridaOptions) withDefaults() DerridaOptions {
+  d := DefaultDerridaOptions
+  if len(o.Distances) == 0 {
+    o.Distances = d.Distances
+  }
+  if o.Samples <= 0 {
+    o.Samples = d.Samples
+  }
+  if o.SlopeRange <= 0 {
+    o.SlopeRange = d.SlopeRange
+  }
+  if o.SpreadInit <= 0 {
+    o.SpreadInit = d.SpreadInit
+  }
+  if o.SpreadSteps <= 0 {
+    o.SpreadSteps = d.SpreadSteps
+  }
+  if o.SpreadTail <= 0 {
+    o.SpreadTail = d.SpreadTail
+  }
+  o.SpreadTail = min(o.SpreadTail, o.SpreadSteps)
+  if o.Tolerance <= 0 {
+    o.Tolerance = d.Tolerance
+  }
+  if o.DamageFloor <= 0 {
+    o.DamageFloor = d.DamageFloor
+  }
+  return o
+}
+
+// DerridaPoint is one point of the Derrida curve.  Rho0 is the distance
+// actually used, a whole number of cells.
+type DerridaPoint struct {
+  Rho0   float64
+  Rho1   float64  // mean over samples
+  StdErr float64  // of Rho1
+}
+
+// DerridaResult is the analysis of one universe.
+type DerridaResult struct {
+  Curve   []DerridaPoint
+  Slope   float64    // of the curve at the origin
+  Damage  float64    // steady-state damage fraction
+  Spread  []float64  // mean damage after each step of damage spreading
+  // Class is Ordered if damage heals or the slope is below 1 by more
+  // than the tolerance, else Chaotic if it is above 1 by more than
+  // that, else Critical.
+  Class   DynamicsClass
+}
+
+// Derrida computes the Derrida curve and damage spreading of u, using
+// only its Evolver, and classifies it.  u itself is not advanced.
+func Derrida(
+  u universes.Universe,
+  rng *substrates.SplitMix64,
+  opts DerridaOptions,
+) DerridaResult {
+  opts = opts.withDefaults()
+  evolve := u.MakeEvolver()
+  g := u.Grid()
+  cells := float64(g.W() * g.H())
+  // walk the trajectory for base configurations
+  base := g.Clone()
+  nextBase := func() *substrates.Grid2d {
+    b := base
+    base = evolve(base, rng)
+    return b
+  }
+  // step evolves a pair, with shared or independent noise
+  step := func(a, b *substrates.Grid2d, pairRng *substrates.SplitMix64) (
+      *substrates.Grid2d, *substrates.Grid2d) {
+    if opts.IndependentNoise {
+      return evolve(a, pairRng), evolve(b, pairRng)
+    }
+    shared := pairRng.NewFromSelf()
+    return evolve(a, shared.Clone()), evolve(b, shared)
+  }
+
+  var res DerridaResult
+  for _, rho0 := range opts.Distances {
+    k := damageCells(rho0, g)
+    var sum, sumSq float64
+    for s := 0; s < opts.Samples; s++ {
+      a := nextBase()
+      b := perturb(a, k, rng)
+      a1, b1 := step(a, b, rng)
+      rho1 := hamming(a1, b1) / cells
+      sum += rho1
+      sumSq += rho1 * rho1
+    }
+    n := float64(opts.Samples)
+    mean := sum / n
+    variance := math.Max(0, sumSq/n - mean*mean)
+    res.Curve = append(res.Curve, DerridaPoint{
+      Rho0:   float64(k) / cells,
+      Rho1:   mean,
+      StdErr: math.Sqrt(variance / n),
+    })
+  }
+  res.Slope = derridaSlope(res.Curve, opts.SlopeRange)
+
+  res.Spread = make([]float64, opts.SpreadSteps)
+  k := damageCells(opts.SpreadInit, g)
+  for s := 0; s < opts.Samples; s++ {
+    a := nextBase()
+    b := perturb(a, k, rng)
+    for t := range res.Spread {
+      a, b = step(a, b, rng)
+      res.Spread[t] += hamming(a, b) / cells / float64(opts.Samples)
+    }
+  }
+  for _, d := range res.Spread[opts.SpreadSteps-opts.SpreadTail:] {
+    res.Damage += d / float64(opts.SpreadTail)
+  }
+
+  switch {
+    case res.Damage <= opts.DamageFloor || res.Slope < 1-opts.Tolerance:
+      res.Class = Ordered
+    case res.Slope > 1+opts.Tolerance:
+      res.Class = Chaotic
+    default:
+      res.Class = Critical
+  }
+  return res
+}
+
+// derridaSlope fits ρ1 = s·ρ0 through the origin to the points with ρ0
+// up to maxRho0, or to the first point if none are that small.
+func derridaSlope(curve []DerridaPoint, maxRho0 float64) float64 {
+  var xy, xx float64
+  for _, p := range curve {
+    if p.Rho0 <= maxRho0 {
+      xy += p.Rho0 * p.Rho1
+      xx += p.Rho0 * p.Rho0
+    }
+  }
+  if xx == 0 {
+    first := curve[0]
+    for _, p := range curve[1:] {
+      if p.Rho0 < first.Rho0 {
+        first = p
+      }
+    }
+    return first.Rho1 / first.Rho0
+  }
+  return xy / xx
+}
+
+// damageCells turns a damage fraction into at least one cell.
+func damageCells(rho float64, g *substrates.Grid2d) int {
+  n := g.W() * g.H()
+  return min(n, max(1, int(math.Round(rho*float64(n)))))
+}
+
+// perturb returns a copy of g with k distinct cells flipped, occupied
+// cells to Empty and empty ones to Live.
+func perturb(
+  g *substrates.Grid2d,
+  k int,
+  rng *substrates.SplitMix64,
+) *substrates.Grid2d {
+  p := g.Clone()
+  flipped := make(map[substrates.Pos]bool, k)
+  for len(flipped) < k {
+    pos := substrates.Pos{X: rng.Intn(g.W()), Y: rng.Intn(g.H())}
+    if flipped[pos] {
+      continue
+    }
+    flipped[pos] = true
+    if substrates.Lethal(g.Get(pos)) {
+      p.SetXY(pos.X, pos.Y, substrates.Empty)
+    } else {
+      p.SetXY(pos.X, pos.Y, substrates.Live)
+    }
+  }
+  return p
+}
@@ -0,0 +1,78 @@
+package metrics
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/universes"
+
+func TestDerridaClasses(t *testing.T) {
+  cases := []struct {
+    rule string
+    want DynamicsClass
+  }{
+    {"B/S", Ordered},        // everything dies at once
+    {"B5678/S45678", Ordered},
+    {"B2/S", Chaotic},       // Seeds
+  }
+  for _, c := range cases {
+    u := universes.NewLifeLikeUniverse(
+        24, 24, c.rule, 40, substrates.NewSplitMix64(1))
+    res := Derrida(u, substrates.NewSplitMix64(2), DerridaOptions{})
+    if res.Class != c.want {
+      t.Errorf("%s: class %v, slope %v, damage %v",
+          c.rule, res.Class, res.Slope, res.Damage)
+    }
+  }
+}
+
+func TestDerridaCurve(t *testing.T) {
+  u := universes.NewConwayUniverse(10, 10, 30, substrates.NewSplitMix64(4))
+  before := u.Grid().Clone()
+  res := Derrida(u, substrates.NewSplitMix64(5), DerridaOptions{
+    Distances: []float64{0.001, 0.25}, Samples: 4,
+    SpreadSteps: 10, SpreadTail: 50,
+  })
+  if !u.Grid().Equal(before) {
+    t.Errorf("Derrida advanced the universe")
+  }
+  // 0.001 of 100 cells rounds up to one cell
+  if len(res.Curve) != 2 || res.Curve[0].Rho0 != 0.01 ||
+      res.Curve[1].Rho0 != 0.25 {
+    t.Errorf("curve %+v", res.Curve)
+  }
+  for _, p := range res.Curve {
+    if p.Rho1 < 0 || p.Rho1 > 1 || p.StdErr < 0 {
+      t.Errorf("point %+v", p)
+    }
+  }
+  if len(res.Spread) != 10 {
+    t.Errorf("%d spreading steps", len(res.Spread))
+  }
+}
+
+func TestDerridaSharedNoise(t *testing.T) {
+  // damage in a noisy universe heals when both copies see the same
+  // noise; different noise keeps them apart
+  u := universes.NewNoisyUniverse(16, 16, 0.3, 30, substrates.NewSplitMix64(1))
+  opts := DerridaOptions{Samples: 5, SpreadSteps: 10, SpreadTail: 5}
+  shared := Derrida(u, substrates.NewSplitMix64(2), opts)
+  opts.IndependentNoise = true
+  independent := Derrida(u, substrates.NewSplitMix64(2), opts)
+  if shared.Class != Ordered {
+    t.Errorf("shared noise: damage %v, class %v", shared.Damage, shared.Class)
+  }
+  if independent.Damage < 10*shared.Damage {
+    t.Errorf("independent noise: damage %v, shared %v",
+        independent.Damage, shared.Damage)
+  }
+}
+
+func TestDynamicsClassText(t *testing.T) {
+  for _, c := range []DynamicsClass{Ordered, Critical, Chaotic} {
+    got, err := ParseDynamicsClass(c.String())
+    if err != nil || got != c {
+      t.Errorf("%v: parsed %v, %v", c, got, err)
+    }
+  }
+  if _, err := ParseDynamicsClass("edge"); err == nil {
+    t.Errorf("parsed an unknown class")
+  }
+}
@@ -50,42 +50,130 @@ func TauL(
   universe universes.Universe,
   rng *substrates.SplitMix64,
 ) float64 {
-  const tauruns = 11
-  manytau := make([]float64, tauruns)
-  for i := 0; i < tauruns; i++ {
-    tau := tauLOne(universe, rng)
-    manytau[i] = tau
+  return TauLDiagnose(universe, rng, DefaultTauLOptions).Tau
+}
+
+// TauLOptions configure TauLDiagnose.  Zero fields take their values
+// from DefaultTauLOptions, except SharedNoise.
+type TauLOptions struct {
+  // Runs is the number of perturbations; τ_L is their median.
+  Runs         int      `json:"runs,omitempty"`
+  // Steps is the length of each run, and the τ_L of non-divergent ones.
+  Steps        int      `json:"steps,omitempty"`
+  // Saturation ends the fit window once d(t) reaches this fraction of
+  // the grid ...
+  Saturation   float64  `json:"saturation,omitempty"`
+  // ... but the window holds at least MinFitPoints points.
+  MinFitPoints int      `json:"min_fit_points,omitempty"`
+  // SharedNoise evolves the base and perturbed grids with the same
+  // random stream, so that only the perturbation can make them differ.
+  // Otherwise each gets its own stream, and in a noisy universe the
+  // noise alone drives them apart.
+  SharedNoise  bool     `json:"shared_noise,omitempty"`
+}
+
+// DefaultTauLOptions are those of TauL.
+var DefaultTauLOptions = TauLOptions{
+  Runs:         11,
+  Steps:        50,
+  Saturation:   0.05,
+  MinFitPoints: 3,
+}
+
+func (o TauLOptions) withDefaults() TauLOptions {
+  if o.Runs <= 0 {
+    o.Runs = DefaultTauLOptions.Runs
+  }
+  if o.Steps <= 0 {
+    o.Steps = DefaultTauLOptions.Steps
+  }
+  if o.Saturation <= 0 {
+    o.Saturation = DefaultTauLOptions.Saturation
+  }
+  if o.MinFitPoints <= 0 {
+    o.MinFitPoints = DefaultTauLOptions.MinFitPoints
+  }
+  o.MinFitPoints = min(o.MinFitPoints, o.Steps)
+  return o
+}
+
+// TauLResult is τ_L with everything that went into it.
+type TauLResult struct {
+  Tau          float64     // median of Runs[i].Tau
+  Runs         []TauLRun
+  Divergent    int         // runs with λ > 0
+  Options      TauLOptions // with defaults filled in
+}
+
+// TauLRun is one perturbation.
+type TauLRun struct {
+  Cell       substrates.Pos  // the flipped cell
+  Divergence []float64       // d(t): cells differing after t steps
+  FitEnd     int             // λ is fitted to log d(t), t < FitEnd
+  Saturated  bool            // d(t) reached the saturation level
+  Lambda     float64
+  Divergent  bool            // λ > 0; otherwise Tau is Options.Steps
+  Tau        float64
+}
+
+// TauLDiagnose estimates τ_L like TauL, with the given options, and
+// returns the details.  With DefaultTauLOptions it draws from rng
+// exactly as TauL does.
+func TauLDiagnose(
+  universe universes.Universe,
+  rng *substrates.SplitMix64,
+  opts TauLOptions,
+) TauLResult {
+  opts = opts.withDefaults()
+  res := TauLResult{Options: opts, Runs: make([]TauLRun, opts.Runs)}
+  manytau := make([]float64, opts.Runs)
+  for i := range res.Runs {
+    res.Runs[i] = tauLOne(universe, rng, opts)
+    manytau[i] = res.Runs[i].Tau
+    if res.Runs[i].Divergent {
+      res.Divergent++
+    }
   }
   // return the median
   sort.Slice(manytau, func(i, j int) bool {
     return manytau[i] < manytau[j]
   })
   medianLocation := len(manytau) / 2
-  median := manytau[medianLocation]
-  return median
+  res.Tau = manytau[medianLocation]
+  return res
 }
 
-// Perturb one bit, measure divergence d(t), fit log(d) = λt + const.
-// If fitted Lyapunov exponent λ ≤ 0 we assign the horizon τ_L = steps.
+// Perturb one bit, measure divergence d(t), fit log(d) = λt + const.
+// If fitted Lyapunov exponent λ ≤ 0 we assign the horizon τ_L = steps.
 // It retains ordering: all non‑divergent runs share
-// the same “very long but finite” τ L.
+// the same “very long but finite” τ L.
 // Log‑scales and heat‑maps behave (no masked cells).
 func tauLOne(
   universe universes.Universe,
   rng *substrates.SplitMix64,
-) float64 {
+  opts TauLOptions,
+) TauLRun {
   base := universe.Grid().Clone()
   pert := base.Clone()
   // flip one random cell
   x := rng.Intn(base.W())
   y := rng.Intn(base.H())
-  val := base.XY(x, y)
-  pert.SetXY(x, y, 1-val)
+  if substrates.Lethal(base.XY(x, y)) {
+    pert.SetXY(x, y, substrates.Empty)
+  } else {
+    pert.SetXY(x, y, substrates.Live)
+  }
 
-  steps := 50
+  steps := opts.Steps
   evolver := universe.MakeEvolver()
-  // rng1, rng2 := rng.Clone(), rng.Clone()  // same noise pattern
-  rng1, rng2 := rng.NewFromSelf(), rng.NewFromSelf()
+  var rng1, rng2 *substrates.SplitMix64
+  if opts.SharedNoise {
+    // same noise pattern; split off so rng does not replay it later
+    rng1 = rng.NewFromSelf()
+    rng2 = rng1.Clone()
+  } else {
+    rng1, rng2 = rng.NewFromSelf(), rng.NewFromSelf()
+  }
   d := make([]float64, steps)
   for t := 0; t < steps; t++ {
     d[t] = hamming(base, pert)
@@ -98,11 +186,16 @@ func tauLOne(
 
   cutoff := 0
   maxDiff := float64(base.W() * base.H())
-  for cutoff < steps && d[cutoff] < 0.05*maxDiff {
+  for cutoff < steps && d[cutoff] < opts.Saturation*maxDiff {
     cutoff++
   }
-  if cutoff < 3 {
-    cutoff = 3
+  run := TauLRun{
+    Cell:       substrates.Pos{X: x, Y: y},
+    Divergence: d,
+    Saturated:  cutoff < steps,
+  }
+  if cutoff < opts.MinFitPoints {
+    cutoff = opts.MinFitPoints
   }
   xs := make([]float64, cutoff)
   ys := make([]float64, cutoff)
@@ -110,11 +203,16 @@ func tauLOne(
     xs[i], ys[i] = float64(i), math.Log(d[i]+1e-9)
   }
   λ := olsSlope(xs, ys)
+  run.FitEnd = cutoff
+  run.Lambda = λ
+  run.Divergent = λ > 0
   if λ <= 0 {
     // return math.Inf(1)
-    return float64(steps) // facilitate log scale
+    run.Tau = float64(steps) // facilitate log scale
+  } else {
+    run.Tau = 1.0 / λ
   }
-  return 1.0 / λ
+  return run
 }
 
 func hamming(a, b *substrates.Grid2d) float64 {
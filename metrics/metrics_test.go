@@ -16,3 +16,56 @@ func TestTauLMonotoneNoise(t *testing.T) {
     t.Fatalf("τ_L not monotone w.r.t noise: %v", tau)
   }
 }
+
+func TestTauLDiagnoseDefaultsMatchTauL(t *testing.T) {
+  u := universes.NewGameOfNoiseUniverse(
+      12, 12, 0.2, 30, substrates.NewSplitMix64(3))
+  rngA, rngB := substrates.NewSplitMix64(8), substrates.NewSplitMix64(8)
+  tau := TauL(u, rngA)
+  res := TauLDiagnose(u, rngB, TauLOptions{})
+  if res.Tau != tau {
+    t.Errorf("TauLDiagnose %v, TauL %v", res.Tau, tau)
+  }
+  if rngA.NextUint64() != rngB.NextUint64() {
+    t.Errorf("TauLDiagnose drew differently from TauL")
+  }
+  if res.Options != DefaultTauLOptions || len(res.Runs) != 11 {
+    t.Errorf("options %+v, %d runs", res.Options, len(res.Runs))
+  }
+  for _, run := range res.Runs {
+    if len(run.Divergence) != 50 || run.Divergence[0] != 1 ||
+        run.FitEnd < 3 || run.Divergent != (run.Lambda > 0) {
+      t.Errorf("run %+v", run)
+    }
+  }
+}
+
+func TestTauLNonDivergent(t *testing.T) {
+  // a lone live cell in an empty Life world dies at once
+  u := universes.NewConwayUniverse(10, 10, 0, substrates.NewSplitMix64(1))
+  res := TauLDiagnose(u, substrates.NewSplitMix64(2), TauLOptions{
+    Runs: 5, Steps: 20, MinFitPoints: 4,
+  })
+  if res.Divergent != 0 || res.Tau != 20 || len(res.Runs) != 5 {
+    t.Fatalf("got %+v", res)
+  }
+  for _, run := range res.Runs {
+    if run.Saturated || run.FitEnd != 20 || run.Divergence[1] != 0 {
+      t.Errorf("run %+v", run)
+    }
+  }
+}
+
+func TestTauLSharedNoise(t *testing.T) {
+  // with shared noise only the perturbation tells the copies apart, so
+  // they stay closer for longer
+  u := universes.NewNoisyUniverse(16, 16, 0.3, 20, substrates.NewSplitMix64(4))
+  opts := TauLOptions{Runs: 7}
+  independent := TauLDiagnose(u, substrates.NewSplitMix64(5), opts)
+  opts.SharedNoise = true
+  shared := TauLDiagnose(u, substrates.NewSplitMix64(5), opts)
+  if !(shared.Tau > independent.Tau) {
+    t.Errorf("shared noise τ_L %v not above independent %v",
+        shared.Tau, independent.Tau)
+  }
+}
@@ -0,0 +1,118 @@
+package metrics
+import "sort"
+
+// ---------- Survival analysis ----------
+// Agents live in discrete ticks.  An agent that dies at tick t (its
+// move in step t lands on a lethal cell) has lifetime t; one still
+// alive when the run ends after T steps is censored at T: it is known
+// to live at least T ticks.
+//
+// References
+// ----------
+// • Kaplan, E. L. & Meier, P. “Nonparametric Estimation from Incomplete
+//   Observations.” *J. Amer. Statist. Assoc.*, 1958.
+
+// Lifetime is one agent's observed life.
+type Lifetime struct {
+  Ticks int
+  Died  bool  // false: censored, still alive after Ticks
+}
+
+// SurvivalCurve is a Kaplan–Meier estimate of S(t) = P(lifetime > t),
+// a step function that drops only at ticks where deaths were seen.
+type SurvivalCurve struct {
+  Ticks  []int      // ticks with deaths, ascending
+  AtRisk []int      // agents alive just before each tick
+  Deaths []int      // deaths at each tick
+  S      []float64  // S just after each tick
+}
+
+// KaplanMeier estimates the survival curve of lives (Kaplan & Meier,
+// 1958).  At a tick with both deaths and censoring, the censored lives
+// count as at risk, the usual convention.
+func KaplanMeier(lives []Lifetime) SurvivalCurve {
+  deaths := map[int]int{}
+  for _, l := range lives {
+    if l.Died {
+      deaths[l.Ticks]++
+    }
+  }
+  var c SurvivalCurve
+  for t := range deaths {
+    c.Ticks = append(c.Ticks, t)
+  }
+  sort.Ints(c.Ticks)
+  s := 1.0
+  for _, t := range c.Ticks {
+    n := atRisk(lives, t)
+    d := deaths[t]
+    s *= 1 - float64(d)/float64(n)
+    c.AtRisk = append(c.AtRisk, n)
+    c.Deaths = append(c.Deaths, d)
+    c.S = append(c.S, s)
+  }
+  return c
+}
+
+// At returns S(t).
+func (c SurvivalCurve) At(t int) float64 {
+  // the last death tick ≤ t
+  i := sort.SearchInts(c.Ticks, t+1) - 1
+  if i < 0 {
+    return 1
+  }
+  return c.S[i]
+}
+
+// Median is the first tick at which S drops to 1/2 or below, and
+// false if it never does.
+func (c SurvivalCurve) Median() (int, bool) {
+  for i, s := range c.S {
+    if s <= 0.5 {
+      return c.Ticks[i], true
+    }
+  }
+  return 0, false
+}
+
+// RestrictedMean is the expected lifetime capped at horizon ticks,
+// Σ_{t<horizon} S(t).  Unlike the plain mean it exists when some agents
+// outlive the run, and it tells dying at tick 3 from dying at tick 49.
+func (c SurvivalCurve) RestrictedMean(horizon int) float64 {
+  sum := 0.0
+  for t := 0; t < horizon; t++ {
+    sum += c.At(t)
+  }
+  return sum
+}
+
+// Hazard returns the discrete hazard h[t] = deaths at t / alive just
+// before t, for t = 1..horizon; h[0] is 0.  Ticks with nobody at risk
+// get 0, as nobody can die there.
+func Hazard(lives []Lifetime, horizon int) []float64 {
+  h := make([]float64, horizon+1)
+  for t := 1; t <= horizon; t++ {
+    n := atRisk(lives, t)
+    if n == 0 {
+      continue
+    }
+    d := 0
+    for _, l := range lives {
+      if l.Died && l.Ticks == t {
+        d++
+      }
+    }
+    h[t] = float64(d) / float64(n)
+  }
+  return h
+}
+
+func atRisk(lives []Lifetime, t int) int {
+  n := 0
+  for _, l := range lives {
+    if l.Ticks >= t {
+      n++
+    }
+  }
+  return n
+}
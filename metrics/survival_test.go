@@ -0,0 +1,52 @@
+package metrics
+import "math"
+import "testing"
+
+func TestKaplanMeier(t *testing.T) {
+  lives := []Lifetime{
+    {1, true}, {2, true}, {2, true}, {3, false}, {4, true}, {5, false},
+  }
+  c := KaplanMeier(lives)
+  wantS := map[int]float64{
+    0: 1, 1: 5.0/6, 2: 0.5, 3: 0.5, 4: 0.25, 5: 0.25, 99: 0.25,
+  }
+  for tick, want := range wantS {
+    if got := c.At(tick); math.Abs(got-want) > 1e-12 {
+      t.Errorf("S(%d) = %v, want %v", tick, got, want)
+    }
+  }
+  if len(c.Ticks) != 3 || c.AtRisk[1] != 5 || c.Deaths[1] != 2 ||
+      c.AtRisk[2] != 2 {
+    t.Errorf("curve %+v", c)
+  }
+  if m, ok := c.Median(); !ok || m != 2 {
+    t.Errorf("median %d, %v", m, ok)
+  }
+  want := 1 + 5.0/6 + 0.5 + 0.5 + 0.25
+  if got := c.RestrictedMean(5); math.Abs(got-want) > 1e-12 {
+    t.Errorf("restricted mean %v, want %v", got, want)
+  }
+  h := Hazard(lives, 6)
+  wantH := []float64{0, 1.0/6, 2.0/5, 0, 1.0/2, 0, 0}
+  for i := range wantH {
+    if math.Abs(h[i]-wantH[i]) > 1e-12 {
+      t.Errorf("hazard %v, want %v", h, wantH)
+      break
+    }
+  }
+}
+
+func TestRestrictedMeanWithoutCensoring(t *testing.T) {
+  lives := []Lifetime{{3, true}, {7, true}, {8, true}}
+  if got := KaplanMeier(lives).RestrictedMean(50); math.Abs(got-6) > 1e-12 {
+    t.Errorf("restricted mean %v, want the plain mean 6", got)
+  }
+  survivors := []Lifetime{{10, false}, {10, false}}
+  c := KaplanMeier(survivors)
+  if _, ok := c.Median(); ok {
+    t.Errorf("median of survivors")
+  }
+  if got := c.RestrictedMean(10); got != 10 {
+    t.Errorf("restricted mean %v, want 10", got)
+  }
+}
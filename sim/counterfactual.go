@@ -0,0 +1,182 @@
+package sim
+import "math"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/universes"
+import "oscarkilo.com/inteluni/agents"
+import "oscarkilo.com/inteluni/metrics"
+
+// ---------- Counterfactual runs ----------
+// In an ordinary run the agents share a random stream with the
+// universe: spawning draws from it before the first tick, so the world
+// a population lives through depends on the mix spawned into it.  A
+// counterfactual run instead gives the world and the agents separate
+// streams, forks the universe once per agent kind, and spawns each fork
+// a population of that kind only, on the same cells and with the same
+// per-agent seeds.  Every kind then faces the same noise realization
+// from the same starting cells, and the difference in how long agent i
+// lived under two kinds is a paired measure of what the policy is
+// worth, free of the between-world variance that dominates small runs.
+
+// CounterfactualResult compares the agent kinds of an experiment on
+// one world history.
+type CounterfactualResult struct {
+  Run
+  Steps int               `json:"steps"`
+  Arms  []CounterfactualArm `json:"arms"`   // one per kind, in Kinds order
+  Pairs []PairedSurvival    `json:"pairs"`  // every two arms, in arm order
+}
+
+// CounterfactualArm is how one kind of agent fared.
+type CounterfactualArm struct {
+  Kind      string          `json:"kind"`
+  Lifetimes []AgentLifetime `json:"lifetimes"`  // by spawn slot
+  MeanLife  float64         `json:"mean_life"`  // restricted to Steps ticks
+  Deaths    int             `json:"deaths"`
+}
+
+// PairedSurvival compares arms A and B slot by slot: agent i of A
+// against agent i of B, which started on the same cell.  Lifetimes of
+// survivors count as Steps, so each difference is one of restricted
+// lifetimes.
+type PairedSurvival struct {
+  A        string  `json:"a"`
+  B        string  `json:"b"`
+  N        int     `json:"n"`
+  MeanDiff float64 `json:"mean_diff"`  // mean of A's lifetime − B's
+  StdErr   float64 `json:"stderr"`     // of MeanDiff
+  Wins     int     `json:"wins"`       // slots where A outlived B
+  Losses   int     `json:"losses"`
+  Ties     int     `json:"ties"`
+}
+
+// Kinds lists the agent kinds in the mix, in the order reactive,
+// predictive, mcts, learning, model.
+func (m AgentMix) Kinds() []string {
+  var kinds []string
+  for _, k := range []struct {
+    kind  string
+    count int
+  }{
+    {agents.KindReactive, m.Reactive},
+    {agents.KindPredictive, m.Predictive},
+    {agents.KindMCTS, m.MCTS},
+    {agents.KindLearning, m.Learning},
+    {agents.KindModel, m.Model},
+  } {
+    if k.count > 0 {
+      kinds = append(kinds, k.kind)
+    }
+  }
+  return kinds
+}
+
+// Counterfactual runs r once per kind in the agent mix, each arm
+// spawning as many agents as the whole mix, all of its kind.  Like
+// Execute it depends only on the experiment and r.
+func (e *Experiment) Counterfactual(r Run) CounterfactualResult {
+  rng := substrates.NewSplitMix64(r.Seed)
+  worldRng, agentRng := rng.NewFromSelf(), rng.NewFromSelf()
+  u := e.NewUniverse(r, worldRng)
+  pop := e.population(r)
+  n := pop.Reactive + pop.Predictive + pop.MCTS + pop.Learning + pop.Model
+  cells := spawnCells(u.Grid(), n, agentRng)
+  seeds := make([]uint64, n)
+  for i := range seeds {
+    seeds[i] = agentRng.NextUint64()
+  }
+  // every arm starts its perception noise from the same seed
+  perception := Perception{Radius: r.Sensing, Noise: e.SensingNoise}
+  var perceptionSeed uint64
+  if perception.Noise > 0 {
+    perceptionSeed = agentRng.NextUint64()
+  }
+
+  res := CounterfactualResult{Run: r, Steps: e.Steps}
+  for _, kind := range e.Agents.Kinds() {
+    world := universes.Fork(u)
+    alive := make([]agents.Agent, n)
+    for i, pos := range cells {
+      alive[i] = agents.NewAgent(
+          kind, i+1, pos, pop, substrates.NewSplitMix64(seeds[i]))
+    }
+    if perception.Noise > 0 {
+      perception.Rng = substrates.NewSplitMix64(perceptionSeed)
+    }
+    _, lives := e.simulate(world, alive, perception, nil)
+    arm := CounterfactualArm{Kind: kind, Lifetimes: lives}
+    km := make([]metrics.Lifetime, len(lives))
+    for i, l := range lives {
+      km[i] = metrics.Lifetime{Ticks: l.Ticks, Died: l.Died}
+      if l.Died {
+        arm.Deaths++
+      }
+    }
+    arm.MeanLife = metrics.KaplanMeier(km).RestrictedMean(e.Steps)
+    res.Arms = append(res.Arms, arm)
+  }
+  for i := range res.Arms {
+    for j := i + 1; j < len(res.Arms); j++ {
+      res.Pairs = append(res.Pairs, pairSurvival(res.Arms[i], res.Arms[j]))
+    }
+  }
+  return res
+}
+
+// pairSurvival compares two arms spawned on the same cells.
+func pairSurvival(a, b CounterfactualArm) PairedSurvival {
+  p := PairedSurvival{A: a.Kind, B: b.Kind, N: len(a.Lifetimes)}
+  if p.N == 0 {
+    return p
+  }
+  var sum, sumSq float64
+  for i, la := range a.Lifetimes {
+    d := float64(la.Ticks - b.Lifetimes[i].Ticks)
+    sum += d
+    sumSq += d * d
+    switch {
+      case d > 0:
+        p.Wins++
+      case d < 0:
+        p.Losses++
+      default:
+        p.Ties++
+    }
+  }
+  n := float64(p.N)
+  p.MeanDiff = sum / n
+  if p.N > 1 {
+    variance := math.Max(0, (sumSq - n*p.MeanDiff*p.MeanDiff) / (n - 1))
+    p.StdErr = math.Sqrt(variance / n)
+  }
+  return p
+}
+
+// spawnCells picks n distinct cells that are not lethal on g.
+func spawnCells(
+    g *substrates.Grid2d,
+    n int,
+    rng *substrates.SplitMix64,
+) []substrates.Pos {
+  free := 0
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      if !substrates.Lethal(g.XY(x, y)) {
+        free++
+      }
+    }
+  }
+  if n > free {
+    panic("not enough free cells to spawn all agents")
+  }
+  cells := make([]substrates.Pos, 0, n)
+  taken := make(map[substrates.Pos]bool, n)
+  for len(cells) < n {
+    pos := substrates.Pos{X: rng.Intn(g.W()), Y: rng.Intn(g.H())}
+    if taken[pos] || substrates.Lethal(g.Get(pos)) {
+      continue
+    }
+    taken[pos] = true
+    cells = append(cells, pos)
+  }
+  return cells
+}
@@ -0,0 +1,81 @@
+package sim
+import "fmt"
+import "reflect"
+import "strings"
+import "testing"
+
+func counterfactualExperiment(t *testing.T, mix string) *Experiment {
+  e, err := ParseExperiment(strings.NewReader(fmt.Sprintf(`{
+    "universe": "gameofnoise", "width": 10, "height": 10, "steps": 15,
+    "noise": 0.1, "complexity": 25, "foresight": 2,
+    "agents": {%s, "mcts_budget": 10}
+  }`, mix)))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return e
+}
+
+func TestCounterfactualArms(t *testing.T) {
+  e := counterfactualExperiment(t, `"reactive": 3, "predictive": 1, "mcts": 1`)
+  r := e.Runs(11)[0]
+  res := e.Counterfactual(r)
+  if len(res.Arms) != 3 || len(res.Pairs) != 3 {
+    t.Fatalf("%d arms, %d pairs", len(res.Arms), len(res.Pairs))
+  }
+  for _, arm := range res.Arms {
+    if len(arm.Lifetimes) != 5 {
+      t.Errorf("%s arm has %d agents", arm.Kind, len(arm.Lifetimes))
+    }
+    for _, l := range arm.Lifetimes {
+      if l.Kind != arm.Kind {
+        t.Errorf("%s arm has a %s agent", arm.Kind, l.Kind)
+      }
+    }
+  }
+  if p := res.Pairs[0]; p.A != "reactive" || p.B != "predictive" ||
+      p.Wins+p.Losses+p.Ties != 5 {
+    t.Errorf("pair %+v", p)
+  }
+  if again := e.Counterfactual(r); !reflect.DeepEqual(res, again) {
+    t.Errorf("same run gave\n%+v\n%+v", res, again)
+  }
+}
+
+func TestCounterfactualWorldIgnoresMix(t *testing.T) {
+  // the reactive arm lives through the same world whatever it is
+  // compared with
+  withPred := counterfactualExperiment(t, `"reactive": 2, "predictive": 2`)
+  withMCTS := counterfactualExperiment(t, `"reactive": 1, "mcts": 3`)
+  r := withPred.Runs(5)[0]
+  a := withPred.Counterfactual(r).Arms[0]
+  b := withMCTS.Counterfactual(r).Arms[0]
+  if a.Kind != "reactive" || !reflect.DeepEqual(a, b) {
+    t.Errorf("reactive arms differ:\n%+v\n%+v", a, b)
+  }
+}
+
+func TestPairSurvival(t *testing.T) {
+  arm := func(kind string, ticks ...int) CounterfactualArm {
+    a := CounterfactualArm{Kind: kind}
+    for i, tk := range ticks {
+      a.Lifetimes = append(a.Lifetimes, AgentLifetime{
+        ID: i + 1, Kind: kind, Ticks: tk, Died: tk < 10,
+      })
+    }
+    return a
+  }
+  p := pairSurvival(arm("a", 10, 4, 6, 3), arm("b", 2, 4, 10, 1))
+  want := PairedSurvival{
+    A: "a", B: "b", N: 4, MeanDiff: 1.5, Wins: 2, Losses: 1, Ties: 1,
+  }
+  se := p.StdErr
+  p.StdErr = 0
+  if p != want {
+    t.Errorf("got %+v", p)
+  }
+  // differences 8, 0, -4, 2: sample variance 25, over n = 4
+  if se != 2.5 {
+    t.Errorf("stderr %v", se)
+  }
+}
@@ -0,0 +1,217 @@
+package sim
+import "encoding/json"
+import "fmt"
+import "io"
+import "os"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/agents"
+
+// Episode is the full log of one run: the world at every tick and what
+// every agent did.  Experiment and Run alone determine it, so replaying
+// them must reproduce the log exactly; see Experiment.Record and
+// CompareEpisodes.  If the experiment names a table for its learning
+// agents, the episode carries the table itself, and replays with it
+// wherever the episode is, whatever became of the file.
+type Episode struct {
+  Experiment *Experiment  `json:"experiment"`
+  Run        Run          `json:"run"`
+  Frames     [][]byte     `json:"frames"`  // see Episode.Frame
+  Agents     []AgentTrack `json:"agents"`
+  Result     RunResult    `json:"result"`  // WallTime is always zero
+  QTable     json.RawMessage `json:"qtable,omitempty"`  // of Experiment.Agents.QTable
+  QTableHash string       `json:"qtable_hash,omitempty"`  // SHA-256 of QTable, compacted
+}
+
+// AgentTrack is one agent's history.  Path[0] is where it spawned and
+// Path[t] where it stood after tick t, having made Moves[t-1].
+type AgentTrack struct {
+  ID        int                `json:"id"`
+  Kind      string             `json:"kind"`
+  Path      []substrates.Pos   `json:"path"`
+  Moves     []substrates.Move  `json:"moves"`
+  DeathTick int                `json:"death_tick,omitempty"`  // 0: survived
+}
+
+// Record runs r like Execute, logging every tick.
+func (e *Experiment) Record(r Run) *Episode {
+  ep := &Episode{
+    Experiment: e,
+    Run:        r,
+    QTable:     e.tableData,
+    QTableHash: e.tableHash(),
+  }
+  track := map[int]*AgentTrack{}
+  spawned := func(g *substrates.Grid2d, pop []agents.Agent) {
+    ep.Frames = append(ep.Frames, encodeFrame(g))
+    ep.Agents = make([]AgentTrack, len(pop))
+    for i, ag := range pop {
+      ep.Agents[i] = AgentTrack{
+        ID:   ag.ID(),
+        Kind: agents.Kind(ag),
+        Path: []substrates.Pos{ag.Pos()},
+      }
+      track[ag.ID()] = &ep.Agents[i]
+    }
+  }
+  observe := func(t Tick) {
+    ep.Frames = append(ep.Frames, encodeFrame(t.Grid))
+    for i, ag := range t.Agents {
+      at := track[ag.ID()]
+      at.Path = append(at.Path, ag.Pos())
+      at.Moves = append(at.Moves, t.Moves[i])
+    }
+    for _, ag := range t.Dead {
+      track[ag.ID()].DeathTick = t.T
+    }
+  }
+  ep.Result = e.Observe(r, spawned, observe)
+  return ep
+}
+
+// Ticks is the number of steps the episode ran.
+func (ep *Episode) Ticks() int {
+  return len(ep.Frames) - 1
+}
+
+// Frame rebuilds the grid after tick t; Frame(0) is the initial grid.
+// Frames are stored row-major, one byte per cell state.
+func (ep *Episode) Frame(t int) *substrates.Grid2d {
+  w, h := ep.Experiment.Width, ep.Experiment.Height
+  cells := ep.Frames[t]
+  if len(cells) != w*h {
+    panic(fmt.Sprintf("frame %d has %d cells, want %dx%d",
+        t, len(cells), w, h))
+  }
+  g := substrates.NewGrid2d(w, h)
+  g.Map(func(x, y, _ int) int {
+    return int(cells[y*w + x])
+  })
+  topology, err := substrates.ParseTopology(ep.Experiment.topologyName())
+  if err != nil {
+    panic(err)
+  }
+  g.SetTopology(topology)
+  return g
+}
+
+// Alive reports whether the agent was alive after tick t.
+func (at *AgentTrack) Alive(t int) bool {
+  return at.DeathTick == 0 || t < at.DeathTick
+}
+
+func encodeFrame(g *substrates.Grid2d) []byte {
+  cells := make([]byte, 0, g.W()*g.H())
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      cells = append(cells, byte(g.XY(x, y)))  // states < 256
+    }
+  }
+  return cells
+}
+
+// WriteEpisode writes ep as indented JSON.
+func WriteEpisode(w io.Writer, ep *Episode) error {
+  enc := json.NewEncoder(w)
+  enc.SetIndent("", "  ")
+  return enc.Encode(ep)
+}
+
+// LoadEpisode reads an episode written by WriteEpisode.
+func LoadEpisode(path string) (*Episode, error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+  var ep Episode
+  if err := json.NewDecoder(f).Decode(&ep); err != nil {
+    return nil, fmt.Errorf("%s: %v", path, err)
+  }
+  if ep.Experiment == nil || len(ep.Frames) == 0 {
+    return nil, fmt.Errorf("%s: not an episode", path)
+  }
+  ep.Experiment.setDefaults()
+  if err := ep.Experiment.validate(); err != nil {
+    return nil, fmt.Errorf("%s: %v", path, err)
+  }
+  if ep.Experiment.Agents.QTable == "" {
+    ep.Experiment.loadQTable()
+  } else {
+    if ep.QTable == nil {
+      return nil, fmt.Errorf("%s: qtable %s is not in the episode",
+          path, ep.Experiment.Agents.QTable)
+    }
+    if err := ep.Experiment.setQTable(ep.QTable); err != nil {
+      return nil, fmt.Errorf("%s: qtable: %v", path, err)
+    }
+    if ep.Experiment.tableHash() != ep.QTableHash {
+      return nil, fmt.Errorf("%s: qtable changed since it was recorded",
+          path)
+    }
+  }
+  return &ep, nil
+}
+
+// CompareEpisodes returns nil if got reproduces want, and otherwise an
+// error describing the earliest difference.
+func CompareEpisodes(want, got *Episode) error {
+  if want.Run != got.Run {
+    return fmt.Errorf("runs differ: %+v vs %+v", want.Run, got.Run)
+  }
+  if len(want.Agents) != len(got.Agents) {
+    return fmt.Errorf("%d agents spawned, want %d",
+        len(got.Agents), len(want.Agents))
+  }
+  for i := range want.Agents {
+    wa, ga := &want.Agents[i], &got.Agents[i]
+    if wa.ID != ga.ID || wa.Kind != ga.Kind || wa.Path[0] != ga.Path[0] {
+      return fmt.Errorf("agent %d spawned as %s %d at %v, want %s %d at %v",
+          i, ga.Kind, ga.ID, ga.Path[0], wa.Kind, wa.ID, wa.Path[0])
+    }
+  }
+  // walk forward in time so the first divergence is reported
+  ticks := want.Ticks()
+  if got.Ticks() > ticks {
+    ticks = got.Ticks()
+  }
+  for t := 0; t <= ticks; t++ {
+    if t > want.Ticks() || t > got.Ticks() {
+      return fmt.Errorf("ran %d ticks, want %d", got.Ticks(), want.Ticks())
+    }
+    if string(want.Frames[t]) != string(got.Frames[t]) {
+      return fmt.Errorf("tick %d: grids differ", t)
+    }
+    for i := range want.Agents {
+      if err := compareTracks(t, &want.Agents[i], &got.Agents[i]); err != nil {
+        return err
+      }
+    }
+  }
+  wr, gr := want.Result, got.Result
+  wr.WallTime, gr.WallTime = 0, 0
+  if !sameResult(wr, gr) {
+    return fmt.Errorf("results differ:\n got %+v\nwant %+v", gr, wr)
+  }
+  return nil
+}
+
+func compareTracks(t int, want, got *AgentTrack) error {
+  if t > 0 && t <= len(want.Moves) && t <= len(got.Moves) &&
+      want.Moves[t-1] != got.Moves[t-1] {
+    return fmt.Errorf("tick %d: agent %d moved %v, want %v",
+        t, want.ID, got.Moves[t-1], want.Moves[t-1])
+  }
+  if (t < len(want.Path)) != (t < len(got.Path)) {
+    return fmt.Errorf("tick %d: agent %d has %d moves, want %d",
+        t, want.ID, len(got.Moves), len(want.Moves))
+  }
+  if t < len(want.Path) && want.Path[t] != got.Path[t] {
+    return fmt.Errorf("tick %d: agent %d at %v, want %v",
+        t, want.ID, got.Path[t], want.Path[t])
+  }
+  if want.Alive(t) != got.Alive(t) {
+    return fmt.Errorf("tick %d: agent %d died at tick %d, want %d",
+        t, want.ID, got.DeathTick, want.DeathTick)
+  }
+  return nil
+}
@@ -0,0 +1,115 @@
+package sim
+import "bytes"
+import "encoding/json"
+import "path/filepath"
+import "strings"
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+// TestRecordedEpisodesReplay guards against unintended behaviour
+// changes in agents and universes.  If a change is intended, regenerate
+// the files with sim/record; the experiment and seed are inside them.
+func TestRecordedEpisodesReplay(t *testing.T) {
+  paths, err := filepath.Glob("testdata/*.episode.json")
+  if err != nil || len(paths) == 0 {
+    t.Fatalf("no episodes in testdata: %v", err)
+  }
+  for _, path := range paths {
+    want, err := LoadEpisode(path)
+    if err != nil {
+      t.Fatal(err)
+    }
+    got := want.Experiment.Record(want.Run)
+    if err := CompareEpisodes(want, got); err != nil {
+      t.Errorf("%s: %v", path, err)
+    }
+  }
+}
+
+func recordSmall(t *testing.T) *Episode {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "gameofnoise", "width": 9, "height": 7, "steps": 15,
+    "noise": 0.2, "complexity": 30, "foresight": 2,
+    "agents": {"reactive": 3, "predictive": 2}
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return e.Record(e.Runs(11)[0])
+}
+
+func TestRecordMatchesExecute(t *testing.T) {
+  ep := recordSmall(t)
+  res := ep.Experiment.Execute(ep.Run)
+  res.WallTime = 0
+  if !sameResult(res, ep.Result) {
+    t.Errorf("Record result\n%+v\ndiffers from Execute\n%+v", ep.Result, res)
+  }
+  if ep.Ticks() != res.StepsRun {
+    t.Errorf("%d frames for %d steps", len(ep.Frames), res.StepsRun)
+  }
+  deaths := 0
+  for _, at := range ep.Agents {
+    if len(at.Path) != len(at.Moves)+1 {
+      t.Errorf("agent %d: %d positions for %d moves",
+          at.ID, len(at.Path), len(at.Moves))
+    }
+    if at.DeathTick != 0 {
+      deaths++
+      if at.DeathTick != len(at.Moves) {
+        t.Errorf("agent %d died at %d after %d moves",
+            at.ID, at.DeathTick, len(at.Moves))
+      }
+      last := at.Path[at.DeathTick]
+      if !substrates.Lethal(ep.Frame(at.DeathTick).Get(last)) {
+        t.Errorf("agent %d died on a safe cell", at.ID)
+      }
+    }
+  }
+  c := res.Collisions
+  if deaths != c.Reactive + c.Predictive + c.MCTS {
+    t.Errorf("%d death ticks for collisions %+v", deaths, c)
+  }
+}
+
+func TestEpisodeJSONRoundTrip(t *testing.T) {
+  want := recordSmall(t)
+  var buf bytes.Buffer
+  if err := WriteEpisode(&buf, want); err != nil {
+    t.Fatal(err)
+  }
+  var got Episode
+  if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+    t.Fatal(err)
+  }
+  if err := CompareEpisodes(want, &got); err != nil {
+    t.Error(err)
+  }
+  if !want.Frame(3).Equal(got.Frame(3)) {
+    t.Errorf("frame 3 differs after round trip")
+  }
+}
+
+func TestCompareEpisodesFindsDivergence(t *testing.T) {
+  want := recordSmall(t)
+  got := want.Experiment.Record(want.Run)
+  at := &got.Agents[0]
+  if len(at.Moves) < 2 {
+    t.Fatalf("agent died too early for this test")
+  }
+  if at.Moves[1] == substrates.Stay {
+    at.Moves[1] = substrates.North
+  } else {
+    at.Moves[1] = substrates.Stay
+  }
+  err := CompareEpisodes(want, got)
+  if err == nil || !strings.Contains(err.Error(), "tick 2:") {
+    t.Errorf("want divergence at tick 2, got %v", err)
+  }
+  got = want.Experiment.Record(want.Run)
+  got.Frames[4][0] ^= 1
+  err = CompareEpisodes(want, got)
+  if err == nil || !strings.Contains(err.Error(), "tick 4: grids") {
+    t.Errorf("want grid divergence at tick 4, got %v", err)
+  }
+}
@@ -0,0 +1,624 @@
+package sim
+import "bytes"
+import "crypto/sha256"
+import "encoding/json"
+import "flag"
+import "fmt"
+import "io"
+import "math"
+import "os"
+import "path/filepath"
+import "strconv"
+import "time"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/universes"
+import "oscarkilo.com/inteluni/agents"
+import "oscarkilo.com/inteluni/metrics"
+
+// Experiment is a parameter sweep read from a JSON file, e.g.
+//
+//   {
+//     "universe":   "gameofnoise",
+//     "width": 32, "height": 32, "steps": 50,
+//     "noise":      {"start": 0.0, "end": 0.7, "step": 0.05},
+//     "complexity": {"start": 10, "end": 60, "step": 5},
+//     "foresight":  [1, 2, 3, 4, 5],
+//     "sensing":    [0, 2, 4],
+//     "agents":     {"reactive": 5, "predictive": 5},
+//     "replicates": 1,
+//     "seed":       42
+//   }
+//
+// Every combination of noise, complexity, foresight and sensing is run
+// replicates times, in that nesting order, with consecutive run IDs.
+// Sensing is the radius agents see (see Perception), 0 for the whole
+// grid, and defaults to [0].
+type Experiment struct {
+  Universe   string       `json:"universe"`
+  Rule       string       `json:"rule,omitempty"`      // lifelike, generations
+  Topology   string       `json:"topology,omitempty"`  // default torus
+  Width      int          `json:"width"`
+  Height     int          `json:"height"`
+  Steps      int          `json:"steps"`
+  Noise      FloatRange   `json:"noise"`               // default [0]; only 0 if the universe takes no noise
+  Complexity IntRange     `json:"complexity"`
+  Foresight  IntRange     `json:"foresight"`
+  Sensing    IntRange     `json:"sensing"`             // default [0]
+  SensingNoise float64    `json:"sensing_noise,omitempty"`  // see Perception
+  Agents     AgentMix     `json:"agents"`
+  Occupancy  string       `json:"occupancy,omitempty"`  // default stack
+  Estimators []string     `json:"estimators,omitempty"` // besides K
+  TauL       metrics.TauLOptions `json:"taul,omitempty"`  // default metrics.DefaultTauLOptions
+  Derrida    *metrics.DerridaOptions `json:"derrida,omitempty"`  // nil: skip
+  Replicates int          `json:"replicates,omitempty"` // default 1
+  Seed       *uint64      `json:"seed,omitempty"`       // default: clock
+
+  table *agents.QTable  // loaded from Agents.QTable, read only
+  tableData json.RawMessage  // the Agents.QTable file, compacted; nil if none
+  dir   string          // a relative Agents.QTable is taken from here
+}
+
+// AgentMix is the population spawned into every run.
+type AgentMix struct {
+  Reactive   int    `json:"reactive"`
+  Predictive int    `json:"predictive"`
+  MCTS       int    `json:"mcts,omitempty"`
+  MCTSBudget int    `json:"mcts_budget,omitempty"`
+  Risk       string `json:"risk,omitempty"`  // see agents.ParseRiskMeasure
+  Learning   int    `json:"learning,omitempty"`
+  QTable     string `json:"qtable,omitempty"`  // file from sim/train, see LoadExperiment; default untrained
+  Model      int    `json:"model,omitempty"`   // plan with a learnt world model
+  Budget     int    `json:"budget,omitempty"`  // predictive and model evolver calls per decision
+  DeadlineMs float64 `json:"deadline_ms,omitempty"`  // wall clock per decision; not reproducible
+}
+
+// FloatRange is a sweep axis given as a number, a list of numbers or
+// an inclusive {"start", "end", "step"} range.
+type FloatRange struct {
+  Values []float64
+}
+
+// IntRange is the integer counterpart of FloatRange.
+type IntRange struct {
+  Values []int
+}
+
+// Run is one point of an experiment's sweep.
+type Run struct {
+  ID         int     `json:"id"`
+  Seed       uint64  `json:"seed"`
+  Noise      float64 `json:"noise"`
+  Complexity int     `json:"complexity"`
+  Foresight  int     `json:"foresight"`
+  Sensing    int     `json:"sensing,omitempty"`
+}
+
+// LoadExperiment reads and validates an experiment file.  A relative
+// qtable path is taken from the file's directory.
+func LoadExperiment(path string) (*Experiment, error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+  e, err := parseExperiment(f, filepath.Dir(path))
+  if err != nil {
+    return nil, fmt.Errorf("%s: %v", path, err)
+  }
+  return e, nil
+}
+
+// ParseExperiment decodes and validates an experiment.  Unknown fields
+// are rejected so that typos do not silently fall back to defaults.  A
+// relative qtable path is taken from the working directory.
+func ParseExperiment(r io.Reader) (*Experiment, error) {
+  return parseExperiment(r, "")
+}
+
+// parseExperiment is ParseExperiment with a relative qtable path taken
+// from dir.  The path is kept as written.
+func parseExperiment(r io.Reader, dir string) (*Experiment, error) {
+  dec := json.NewDecoder(r)
+  dec.DisallowUnknownFields()
+  e := Experiment{dir: dir}
+  if err := dec.Decode(&e); err != nil {
+    return nil, err
+  }
+  e.setDefaults()
+  if err := e.validate(); err != nil {
+    return nil, err
+  }
+  if err := e.loadQTable(); err != nil {
+    return nil, err
+  }
+  return &e, nil
+}
+
+// loadQTable reads the learning agents' table, or makes an untrained
+// one if the experiment names none.
+func (e *Experiment) loadQTable() error {
+  if e.Agents.QTable == "" {
+    e.table = agents.NewQTable(agents.DefaultQRadius, 0, 0)
+    e.tableData = nil
+    return nil
+  }
+  path := e.Agents.QTable
+  if e.dir != "" && !filepath.IsAbs(path) {
+    path = filepath.Join(e.dir, path)
+  }
+  data, err := os.ReadFile(path)
+  if err != nil {
+    return err
+  }
+  if err := e.setQTable(data); err != nil {
+    return fmt.Errorf("%s: %v", path, err)
+  }
+  return nil
+}
+
+// setQTable loads the learning agents' table from the contents of its
+// file, which episodes embed.
+func (e *Experiment) setQTable(data []byte) error {
+  var buf bytes.Buffer
+  if err := json.Compact(&buf, data); err != nil {
+    return err
+  }
+  table, err := agents.LoadQTable(bytes.NewReader(buf.Bytes()))
+  if err != nil {
+    return err
+  }
+  e.table, e.tableData = table, buf.Bytes()
+  return nil
+}
+
+// tableHash is the SHA-256 of the compacted table file, "" if none.
+func (e *Experiment) tableHash() string {
+  if e.tableData == nil {
+    return ""
+  }
+  return fmt.Sprintf("%x", sha256.Sum256(e.tableData))
+}
+
+func (e *Experiment) setDefaults() {
+  if len(e.Noise.Values) == 0 {
+    e.Noise.Values = []float64{0}
+  }
+  if len(e.Sensing.Values) == 0 {
+    e.Sensing.Values = []int{0}
+  }
+  if e.Replicates == 0 {
+    e.Replicates = 1
+  }
+  if e.Agents.Risk == "" {
+    e.Agents.Risk = "worst"
+  }
+  if e.Occupancy == "" {
+    e.Occupancy = Stack.String()
+  }
+}
+
+func (e *Experiment) validate() error {
+  spec, err := universes.Lookup(e.Universe)
+  if err != nil {
+    return err
+  }
+  if e.Width <= 0 || e.Height <= 0 {
+    return fmt.Errorf("grid must be at least 1x1, got %dx%d",
+        e.Width, e.Height)
+  }
+  if e.Steps <= 0 {
+    return fmt.Errorf("steps must be positive")
+  }
+  if e.Replicates < 0 {
+    return fmt.Errorf("replicates must not be negative")
+  }
+  if len(e.Complexity.Values) == 0 || len(e.Foresight.Values) == 0 {
+    return fmt.Errorf("complexity and foresight need at least one value")
+  }
+  for _, n := range e.Noise.Values {
+    if n < 0 || n > 1 {
+      return fmt.Errorf("noise %v outside 0..1", n)
+    }
+    // it would only relabel identical runs
+    if n != 0 && !e.takesNoise() {
+      return fmt.Errorf("universe %q takes no noise, got %v", e.Universe, n)
+    }
+  }
+  // every point of the sweep must make a valid universe
+  for _, n := range e.Noise.Values {
+    for _, c := range e.Complexity.Values {
+      if _, err := spec.Check(e.params(n, c)); err != nil {
+        return err
+      }
+    }
+  }
+  for _, f := range e.Foresight.Values {
+    planners := e.Agents.Predictive + e.Agents.MCTS + e.Agents.Model
+    if f <= 0 && planners > 0 {
+      return fmt.Errorf("foresight %d must be positive", f)
+    }
+  }
+  for _, s := range e.Sensing.Values {
+    p := Perception{Radius: s, Noise: e.SensingNoise}
+    if err := p.Validate(); err != nil {
+      return err
+    }
+  }
+  if e.Agents.Budget < 0 || e.Agents.DeadlineMs < 0 {
+    return fmt.Errorf("budget and deadline_ms must not be negative")
+  }
+  if e.Agents.MCTS > 0 && e.Agents.MCTSBudget <= 0 {
+    return fmt.Errorf("mcts agents need a positive mcts_budget")
+  }
+  if _, err := substrates.ParseTopology(e.topologyName()); err != nil {
+    return err
+  }
+  if _, err := agents.ParseRiskMeasure(e.Agents.Risk); err != nil {
+    return err
+  }
+  if _, err := ParseOccupancy(e.Occupancy); err != nil {
+    return err
+  }
+  if _, err := e.estimators(); err != nil {
+    return err
+  }
+  if t := e.TauL; t.Runs < 0 || t.Steps < 0 || t.MinFitPoints < 0 ||
+      t.Saturation < 0 || t.Saturation > 1 {
+    return fmt.Errorf("taul options out of range: %+v", t)
+  }
+  if d := e.Derrida; d != nil {
+    bad := d.Samples < 0 || d.SlopeRange < 0 || d.SpreadInit < 0 ||
+        d.SpreadInit > 1 || d.SpreadSteps < 0 || d.SpreadTail < 0 ||
+        d.Tolerance < 0 || d.DamageFloor < 0
+    for _, rho := range d.Distances {
+      bad = bad || rho <= 0 || rho > 1
+    }
+    if bad {
+      return fmt.Errorf("derrida options out of range: %+v", *d)
+    }
+  }
+  return nil
+}
+
+// estimators parses Estimators; see metrics.ParseComplexityEstimator.
+func (e *Experiment) estimators() ([]metrics.ComplexityEstimator, error) {
+  var ests []metrics.ComplexityEstimator
+  seen := map[string]bool{}
+  for _, name := range e.Estimators {
+    est, err := metrics.ParseComplexityEstimator(name)
+    if err != nil {
+      return nil, err
+    }
+    if seen[est.Name()] {
+      return nil, fmt.Errorf("estimator %q listed twice", name)
+    }
+    seen[est.Name()] = true
+    ests = append(ests, est)
+  }
+  return ests, nil
+}
+
+func (e *Experiment) topologyName() string {
+  if e.Topology == "" {
+    return substrates.Torus.String()
+  }
+  return e.Topology
+}
+
+// ChooseSeed returns the seed of the sweep, the same for every command:
+// the -seed flag of fs if it was set on the command line, else the
+// experiment's seed, else the clock, in which case clock is true.
+func (e *Experiment) ChooseSeed(fs *flag.FlagSet) (seed uint64, clock bool) {
+  set := false
+  fs.Visit(func(f *flag.Flag) {
+    if f.Name == "seed" {
+      n, err := strconv.ParseUint(f.Value.String(), 10, 64)
+      if err != nil {
+        panic("-seed is not a uint64 flag")
+      }
+      seed, set = n, true
+    }
+  })
+  switch {
+    case set:
+      return seed, false
+    case e.Seed != nil:
+      return *e.Seed, false
+    default:
+      return uint64(time.Now().UnixNano()), true
+  }
+}
+
+// Runs expands the sweep.  Run i is seeded with seed + i.
+func (e *Experiment) Runs(seed uint64) []Run {
+  var runs []Run
+  for _, noise := range e.Noise.Values {
+    for _, comp := range e.Complexity.Values {
+      for _, fs := range e.Foresight.Values {
+        for _, sense := range e.Sensing.Values {
+          for rep := 0; rep < e.Replicates; rep++ {
+            id := len(runs)
+            runs = append(runs, Run{
+              ID:         id,
+              Seed:       seed + uint64(id),
+              Noise:      noise,
+              Complexity: comp,
+              Foresight:  fs,
+              Sensing:    sense,
+            })
+          }
+        }
+      }
+    }
+  }
+  return runs
+}
+
+// Execute simulates one run and returns its result.  The outcome
+// depends only on the experiment and r, never on other runs, so runs
+// may execute concurrently and in any order.  Only WallTime varies
+// between executions of the same run.
+func (e *Experiment) Execute(r Run) RunResult {
+  start := time.Now()
+  res := e.Observe(r, nil, nil)
+  res.WallTime = time.Since(start)
+  return res
+}
+
+// Observe runs r like Execute but without timing it.  If spawned is not
+// nil it is called with the initial grid and agents before the first
+// step, and observer, if not nil, after every step.
+func (e *Experiment) Observe(
+    r Run,
+    spawned func(*substrates.Grid2d, []agents.Agent),
+    observer func(Tick),
+) RunResult {
+  rng := substrates.NewSplitMix64(r.Seed)
+  u := e.NewUniverse(r, rng)
+  agentsPop := agents.SpawnPopulation(u.Grid(), e.population(r), rng)
+  if spawned != nil {
+    spawned(u.Grid(), agentsPop)
+  }
+  frames, lives := e.simulate(u, agentsPop, e.perception(r, rng), observer)
+  estimators, err := e.estimators()
+  if err != nil {
+    panic(err)
+  }
+  return Report(e.result(r), frames, u, lives, rng, ReportOptions{
+    Estimators: estimators,
+    TauL:       e.TauL,
+    Derrida:    e.Derrida,
+  })
+}
+
+// simulate runs pop in u for the experiment's steps under its
+// occupancy rule and perception p, and returns the frames and every
+// agent's lifetime.  observer, if not nil, is called after every step.
+func (e *Experiment) simulate(
+    u universes.Universe,
+    pop []agents.Agent,
+    p Perception,
+    observer func(Tick),
+) ([]*substrates.Grid2d, []AgentLifetime) {
+  occupancy, err := ParseOccupancy(e.Occupancy)
+  if err != nil {
+    panic(err)
+  }
+  spawned := append([]agents.Agent(nil), pop...)
+  deathTicks := map[int]int{}
+  observe := func(t Tick) {
+    for _, ag := range t.Dead {
+      deathTicks[ag.ID()] = t.T
+    }
+    if observer != nil {
+      observer(t)
+    }
+  }
+  frames := Simulate(u, &pop, e.Steps, Options{
+    Observer:   observe,
+    Occupancy:  occupancy,
+    Perception: p,
+    Deadline:   time.Duration(e.Agents.DeadlineMs * float64(time.Millisecond)),
+  })
+  return frames, Lifetimes(spawned, deathTicks, len(frames)-1)
+}
+
+// perception is what agents see in run r.  Noisy perception draws
+// from a stream split off rng, after spawning, so runs without it draw
+// exactly as before.
+func (e *Experiment) perception(r Run, rng *substrates.SplitMix64) Perception {
+  p := Perception{Radius: r.Sensing, Noise: e.SensingNoise}
+  if p.Noise > 0 {
+    p.Rng = rng.NewFromSelf()
+  }
+  return p
+}
+
+// population is the agent mix of run r, with learning frozen.
+func (e *Experiment) population(r Run) agents.Population {
+  risk, err := agents.ParseRiskMeasure(e.Agents.Risk)
+  if err != nil {
+    panic(err)
+  }
+  return agents.Population{
+    Reactive:   e.Agents.Reactive,
+    Predictive: e.Agents.Predictive,
+    MCTS:       e.Agents.MCTS,
+    Learning:   e.Agents.Learning,
+    Model:      e.Agents.Model,
+    Budget:     e.Agents.Budget,
+    Foresight:  r.Foresight,
+    MCTSBudget: e.Agents.MCTSBudget,
+    Risk:       risk,
+    QTable:     e.table,
+  }
+}
+
+// result fills in the identity and parameters of run r.
+func (e *Experiment) result(r Run) RunResult {
+  res := RunResult{
+    ID:         r.ID,
+    Seed:       r.Seed,
+    Universe:   e.Universe,
+    Rule:       e.Rule,
+    Topology:   e.topologyName(),
+    Width:      e.Width,
+    Height:     e.Height,
+    Steps:      e.Steps,
+    Noise:      r.Noise,
+    Complexity: r.Complexity,
+    Foresight:  r.Foresight,
+    Sensing:    r.Sensing,
+    SensingNoise: e.SensingNoise,
+    Risk:       e.Agents.Risk,
+    Occupancy:  e.Occupancy,
+    Spawned: AgentCounts{
+      Reactive:   e.Agents.Reactive,
+      Predictive: e.Agents.Predictive,
+      MCTS:       e.Agents.MCTS,
+      Learning:   e.Agents.Learning,
+      Model:      e.Agents.Model,
+    },
+  }
+  if e.Agents.MCTS > 0 {
+    res.MCTSBudget = e.Agents.MCTSBudget
+  }
+  if e.Agents.Predictive > 0 || e.Agents.Model > 0 {
+    res.Budget = e.Agents.Budget
+  }
+  res.DeadlineMs = e.Agents.DeadlineMs
+  return res
+}
+
+// NewUniverse builds the experiment's universe for run r.
+func (e *Experiment) NewUniverse(
+    r Run,
+    rng *substrates.SplitMix64,
+) universes.Universe {
+  u, err := universes.New(
+      e.Universe, e.Width, e.Height, e.params(r.Noise, r.Complexity), rng)
+  if err != nil {
+    panic(err)
+  }
+  topology, err := substrates.ParseTopology(e.topologyName())
+  if err != nil {
+    panic(err)
+  }
+  return universes.WithTopology(u, topology)
+}
+
+// params are the universe parameters at one point of the sweep.  Noise
+// is a sweep axis of every experiment but only goes to universes that
+// take it; validate allows no other noise than 0 for the rest.
+func (e *Experiment) params(noise float64, complexity int) universes.Params {
+  p := universes.Params{"complexity": complexity}
+  if e.takesNoise() {
+    p["noise"] = noise
+  }
+  if e.Rule != "" {
+    p["rule"] = e.Rule
+  }
+  return p
+}
+
+// takesNoise reports whether the universe has a noise parameter.
+func (e *Experiment) takesNoise() bool {
+  spec, err := universes.Lookup(e.Universe)
+  if err != nil {
+    return false
+  }
+  for _, param := range spec.Params {
+    if param.Name == "noise" {
+      return true
+    }
+  }
+  return false
+}
+
+func (fr *FloatRange) UnmarshalJSON(data []byte) error {
+  var spec struct {
+    Start *float64 `json:"start"`
+    End   *float64 `json:"end"`
+    Step  *float64 `json:"step"`
+  }
+  switch {
+    case bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")):
+      return json.Unmarshal(data, &fr.Values)
+    case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+      if err := strictUnmarshal(data, &spec); err != nil {
+        return err
+      }
+      if spec.Start == nil || spec.End == nil || spec.Step == nil {
+        return fmt.Errorf("range needs start, end and step")
+      }
+      if *spec.Step <= 0 || *spec.End < *spec.Start {
+        return fmt.Errorf("range %v..%v step %v is empty",
+            *spec.Start, *spec.End, *spec.Step)
+      }
+      // count steps with a little slack so 0.1..0.9 by 0.1 includes 0.9
+      n := int(math.Floor((*spec.End - *spec.Start) / *spec.Step + 1e-9))
+      fr.Values = make([]float64, n+1)
+      for i := range fr.Values {
+        v := *spec.Start + float64(i) * *spec.Step
+        fr.Values[i] = math.Round(v*1e9) / 1e9
+      }
+      return nil
+    default:
+      var v float64
+      if err := json.Unmarshal(data, &v); err != nil {
+        return err
+      }
+      fr.Values = []float64{v}
+      return nil
+  }
+}
+
+// MarshalJSON writes the expanded values, which UnmarshalJSON accepts.
+func (fr FloatRange) MarshalJSON() ([]byte, error) {
+  return json.Marshal(fr.Values)
+}
+
+func (ir *IntRange) UnmarshalJSON(data []byte) error {
+  var spec struct {
+    Start *int `json:"start"`
+    End   *int `json:"end"`
+    Step  *int `json:"step"`
+  }
+  switch {
+    case bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")):
+      return json.Unmarshal(data, &ir.Values)
+    case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+      if err := strictUnmarshal(data, &spec); err != nil {
+        return err
+      }
+      if spec.Start == nil || spec.End == nil || spec.Step == nil {
+        return fmt.Errorf("range needs start, end and step")
+      }
+      if *spec.Step <= 0 || *spec.End < *spec.Start {
+        return fmt.Errorf("range %d..%d step %d is empty",
+            *spec.Start, *spec.End, *spec.Step)
+      }
+      ir.Values = nil
+      for v := *spec.Start; v <= *spec.End; v += *spec.Step {
+        ir.Values = append(ir.Values, v)
+      }
+      return nil
+    default:
+      var v int
+      if err := json.Unmarshal(data, &v); err != nil {
+        return err
+      }
+      ir.Values = []int{v}
+      return nil
+  }
+}
+
+func (ir IntRange) MarshalJSON() ([]byte, error) {
+  return json.Marshal(ir.Values)
+}
+
+func strictUnmarshal(data []byte, v interface{}) error {
+  dec := json.NewDecoder(bytes.NewReader(data))
+  dec.DisallowUnknownFields()
+  return dec.Decode(v)
+}
@@ -0,0 +1,381 @@
+package sim
+import "bytes"
+import "flag"
+import "fmt"
+import "os"
+import "path/filepath"
+import "reflect"
+import "strings"
+import "testing"
+import "oscarkilo.com/inteluni/agents"
+import "oscarkilo.com/inteluni/substrates"
+
+func TestParseExperimentRanges(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "gameofnoise",
+    "width": 8, "height": 6, "steps": 3,
+    "noise": {"start": 0.1, "end": 0.3, "step": 0.1},
+    "complexity": 20,
+    "foresight": [1, 3],
+    "agents": {"reactive": 1, "predictive": 1},
+    "replicates": 2
+  }`))
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  wantNoise := []float64{0.1, 0.2, 0.3}
+  if len(e.Noise.Values) != len(wantNoise) {
+    t.Fatalf("noise values %v, want %v", e.Noise.Values, wantNoise)
+  }
+  for i, v := range wantNoise {
+    if e.Noise.Values[i] != v {
+      t.Fatalf("noise values %v, want %v", e.Noise.Values, wantNoise)
+    }
+  }
+  runs := e.Runs(100)
+  if len(runs) != 3*1*2*2 {
+    t.Fatalf("expected 12 runs, got %d", len(runs))
+  }
+  for i, r := range runs {
+    if r.ID != i || r.Seed != 100+uint64(i) {
+      t.Fatalf("run %d has ID %d seed %d", i, r.ID, r.Seed)
+    }
+  }
+  // foresight varies faster than noise, replicates fastest
+  if runs[1].Foresight != 1 || runs[2].Foresight != 3 ||
+      runs[4].Noise != 0.2 {
+    t.Fatalf("unexpected sweep order: %+v", runs[:5])
+  }
+}
+
+func TestParseExperimentRejects(t *testing.T) {
+  bad := []string{
+    `{"universe": "mars", "width": 4, "height": 4, "steps": 1,
+      "complexity": 10, "foresight": 1}`,
+    `{"universe": "noisy", "width": 4, "height": 4, "steps": 1,
+      "complexity": 10, "foresight": 1, "noize": 0.1}`,
+    `{"universe": "noisy", "width": 4, "height": 4, "steps": 1,
+      "noise": 1.5, "complexity": 10, "foresight": 1}`,
+    `{"universe": "gameoflife", "width": 4, "height": 4, "steps": 1,
+      "noise": [0, 0.1], "complexity": 10, "foresight": 1}`,
+    `{"universe": "lifelike", "rule": "B3", "width": 4, "height": 4,
+      "steps": 1, "complexity": 10, "foresight": 1}`,
+    `{"universe": "noisy", "topology": "sphere", "width": 4, "height": 4,
+      "steps": 1, "complexity": 10, "foresight": 1}`,
+    `{"universe": "noisy", "width": 4, "height": 4, "steps": 1,
+      "complexity": {"start": 10, "end": 5, "step": 1}, "foresight": 1}`,
+    `{"universe": "noisy", "width": 4, "height": 4, "steps": 1,
+      "complexity": 10, "foresight": 1,
+      "agents": {"predictive": 1, "risk": "cvar"}}`,
+    `{"universe": "noisy", "width": 4, "height": 4, "steps": 1,
+      "complexity": 10, "foresight": 1, "occupancy": "crush"}`,
+    `{"universe": "noisy", "width": 4, "height": 4, "steps": 1,
+      "complexity": 10, "foresight": 1, "taul": {"saturation": 2}}`,
+    `{"universe": "noisy", "width": 4, "height": 4, "steps": 1,
+      "complexity": 10, "foresight": 1, "taul": {"run": 5}}`,
+    `{"universe": "noisy", "width": 4, "height": 4, "steps": 1,
+      "complexity": 10, "foresight": 1, "derrida": {"distances": [0.5, 2]}}`,
+  }
+  for _, src := range bad {
+    if _, err := ParseExperiment(strings.NewReader(src)); err == nil {
+      t.Errorf("expected error for %s", src)
+    }
+  }
+}
+
+func TestNewUniverseAppliesTopologyAndRule(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "generations", "rule": "/2/3", "topology": "klein",
+    "width": 5, "height": 4, "steps": 1,
+    "complexity": 30, "foresight": 1
+  }`))
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  u := e.NewUniverse(e.Runs(1)[0], substrates.NewSplitMix64(1))
+  if u.Grid().Topology() != substrates.KleinBottle {
+    t.Errorf("topology not applied")
+  }
+  if u.Grid().W() != 5 || u.Grid().H() != 4 {
+    t.Errorf("grid is %dx%d, want 5x4", u.Grid().W(), u.Grid().H())
+  }
+}
+
+func TestBundledExperimentsLoad(t *testing.T) {
+  paths, err := filepath.Glob("experiments/*.json")
+  if err != nil || len(paths) == 0 {
+    t.Fatalf("no bundled experiments found: %v", err)
+  }
+  for _, p := range paths {
+    if _, err := LoadExperiment(p); err != nil {
+      t.Errorf("%v", err)
+    }
+  }
+}
+
+func TestExperimentTauLOptions(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "gameofnoise", "width": 8, "height": 8, "steps": 3,
+    "noise": 0.1, "complexity": 30, "foresight": 1,
+    "agents": {"reactive": 1},
+    "taul": {"runs": 3, "steps": 20, "shared_noise": true}
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  res := e.Execute(e.Runs(1)[0])
+  if res.TauLRuns != 3 || res.TauLDivergent > 3 || res.TauL > 20 {
+    t.Errorf("TauL %v from %d runs, %d divergent",
+        res.TauL, res.TauLRuns, res.TauLDivergent)
+  }
+}
+
+func TestExperimentDerrida(t *testing.T) {
+  spec := `{
+    "universe": "lifelike", "rule": "B2/S", "width": 12, "height": 12,
+    "steps": 3, "complexity": 30, "foresight": 1,
+    "agents": {"reactive": 1}%s
+  }`
+  plain, err := ParseExperiment(strings.NewReader(fmt.Sprintf(spec, "")))
+  if err != nil {
+    t.Fatal(err)
+  }
+  analysed, err := ParseExperiment(strings.NewReader(fmt.Sprintf(spec,
+      `, "derrida": {"samples": 5, "spread_steps": 20}`)))
+  if err != nil {
+    t.Fatal(err)
+  }
+  r := plain.Runs(1)[0]
+  want, got := plain.Execute(r), analysed.Execute(r)
+  if want.Dynamics != "" || got.Dynamics != "chaotic" ||
+      got.DerridaSlope <= 1 || got.Damage <= 0 {
+    t.Errorf("dynamics %q, %q, slope %v, damage %v",
+        want.Dynamics, got.Dynamics, got.DerridaSlope, got.Damage)
+  }
+  // the analysis comes last and changes nothing else
+  got.Dynamics, got.DerridaSlope, got.Damage = "", 0, 0
+  want.WallTime, got.WallTime = 0, 0
+  if !reflect.DeepEqual(want, got) {
+    t.Errorf("Derrida changed the run:\n%+v\n%+v", want, got)
+  }
+}
+
+func TestLearningAgents(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "gameofnoise", "width": 10, "height": 10, "steps": 10,
+    "noise": 0.05, "complexity": 25, "foresight": 1,
+    "agents": {"reactive": 2, "learning": 3}
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  table := agents.NewQTable(1, 0, 0)
+  r := e.Runs(3)[0]
+  lives := e.Train(table, r)
+  learning := 0
+  for _, l := range lives {
+    if l.Kind == agents.KindLearning {
+      learning++
+    }
+  }
+  if learning != 3 || table.States() == 0 {
+    t.Errorf("%d learning agents, %d states learnt", learning, table.States())
+  }
+  // sweeps use the experiment's own table, frozen
+  res := e.Execute(r)
+  if res.Spawned.Learning != 3 || e.table.States() != 0 {
+    t.Errorf("spawned %+v, %d states", res.Spawned, e.table.States())
+  }
+}
+
+// TestQTableFile checks that a table is found next to its experiment,
+// and that episodes carry it, so that they replay without the file but
+// not with an edited table.
+func TestQTableFile(t *testing.T) {
+  dir := t.TempDir()
+  path := filepath.Join(dir, "exp.json")
+  err := os.WriteFile(path, []byte(`{
+    "universe": "gameofnoise", "width": 8, "height": 8, "steps": 5,
+    "noise": 0.05, "complexity": 25, "foresight": 1,
+    "agents": {"reactive": 1, "learning": 2, "qtable": "q.json"}
+  }`), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  table := agents.NewQTable(1, 0.5, 0)
+  var buf bytes.Buffer
+  if err := table.Save(&buf); err != nil {
+    t.Fatal(err)
+  }
+  qtable := filepath.Join(dir, "q.json")
+  if err := os.WriteFile(qtable, buf.Bytes(), 0644); err != nil {
+    t.Fatal(err)
+  }
+  e, err := LoadExperiment(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if e.Agents.QTable != "q.json" {
+    t.Errorf("qtable path %q, not as written", e.Agents.QTable)
+  }
+  e.Train(table, e.Runs(1)[0])
+  buf.Reset()
+  if err := table.Save(&buf); err != nil {
+    t.Fatal(err)
+  }
+  if err := os.WriteFile(qtable, buf.Bytes(), 0644); err != nil {
+    t.Fatal(err)
+  }
+  if e, err = LoadExperiment(path); err != nil {
+    t.Fatal(err)
+  }
+  want := e.Record(e.Runs(1)[0])
+  write := func(ep *Episode) string {
+    var buf bytes.Buffer
+    if err := WriteEpisode(&buf, ep); err != nil {
+      t.Fatal(err)
+    }
+    episode := filepath.Join(t.TempDir(), "ep.json")
+    if err := os.WriteFile(episode, buf.Bytes(), 0644); err != nil {
+      t.Fatal(err)
+    }
+    return episode
+  }
+  episode := write(want)
+  if err := os.Remove(qtable); err != nil {
+    t.Fatal(err)
+  }
+  ep, err := LoadEpisode(episode)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if err := CompareEpisodes(ep, ep.Experiment.Record(ep.Run)); err != nil {
+    t.Errorf("replay without the table file: %v", err)
+  }
+  edited := *want
+  edited.QTable = bytes.Replace(want.QTable,
+      []byte(`"alpha":0.5`), []byte(`"alpha":0.25`), 1)
+  if bytes.Equal(edited.QTable, want.QTable) {
+    t.Fatalf("no alpha in %s", want.QTable)
+  }
+  if _, err := LoadEpisode(write(&edited)); err == nil {
+    t.Errorf("replaying with an edited table was allowed")
+  }
+}
+
+func TestModelAgents(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "gameoflife", "width": 12, "height": 12, "steps": 8,
+    "complexity": 25, "foresight": 2,
+    "agents": {"predictive": 2, "model": 3}
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  r := e.Runs(5)[0]
+  res := e.Execute(r)
+  if res.Spawned.Model != 3 || len(res.Lives(agents.KindModel)) != 3 {
+    t.Fatalf("spawned %+v", res.Spawned)
+  }
+  if res.ModelAccuracy <= 0 || res.ModelAccuracy > 1 {
+    t.Errorf("model accuracy %v", res.ModelAccuracy)
+  }
+  again := e.Execute(r)
+  again.WallTime = res.WallTime
+  if !sameResult(res, again) {
+    t.Errorf("model agents are not deterministic")
+  }
+}
+
+func TestSensing(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "gameofnoise", "width": 12, "height": 12, "steps": 8,
+    "noise": 0.05, "complexity": 25, "foresight": [1, 2],
+    "sensing": [0, 2], "sensing_noise": 0.02,
+    "agents": {"reactive": 2, "predictive": 2, "mcts": 1, "mcts_budget": 8,
+               "learning": 1, "model": 2}
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  runs := e.Runs(9)
+  if len(runs) != 4 || runs[1].Foresight != 1 || runs[1].Sensing != 2 {
+    t.Fatalf("runs %+v", runs)
+  }
+  res := e.Execute(runs[1])
+  again := e.Execute(runs[1])
+  again.WallTime = res.WallTime
+  if res.Sensing != 2 || res.SensingNoise != 0.02 ||
+      !sameResult(res, again) {
+    t.Errorf("sensing run not recorded or not deterministic: %+v", res)
+  }
+  for _, bad := range []string{`"sensing": -1`, `"sensing_noise": 2`} {
+    _, err := ParseExperiment(strings.NewReader(`{
+      "universe": "gameoflife", "width": 8, "height": 8, "steps": 5,
+      "complexity": 20, "foresight": 1, "agents": {"reactive": 1}, ` +
+        bad + `}`))
+    if err == nil {
+      t.Errorf("accepted %s", bad)
+    }
+  }
+}
+
+func TestBudget(t *testing.T) {
+  exp := func(budget string) *Experiment {
+    e, err := ParseExperiment(strings.NewReader(`{
+      "universe": "gameofnoise", "width": 12, "height": 12, "steps": 10,
+      "noise": 0.05, "complexity": 20, "foresight": 3,
+      "agents": {"reactive": 2, "predictive": 3` + budget + `}
+    }`))
+    if err != nil {
+      t.Fatal(err)
+    }
+    return e
+  }
+  free := exp("")
+  capped := exp(`, "budget": 40`)
+  r := free.Runs(4)[0]
+  a, b := free.Execute(r), capped.Execute(r)
+  if a.Calls.Reactive != 0 || a.Calls.Predictive <= 40 {
+    t.Errorf("unbudgeted calls per decision %+v", a.Calls)
+  }
+  if b.Budget != 40 || b.Calls.Predictive > 40 || b.Calls.Predictive == 0 {
+    t.Errorf("budget %d, calls per decision %+v", b.Budget, b.Calls)
+  }
+  deadline := exp(`, "deadline_ms": 1000`)
+  if res := deadline.Execute(r); res.DeadlineMs != 1000 {
+    t.Errorf("deadline not recorded: %v", res.DeadlineMs)
+  }
+  if _, err := ParseExperiment(strings.NewReader(`{
+    "universe": "gameoflife", "width": 8, "height": 8, "steps": 5,
+    "complexity": 20, "foresight": 1,
+    "agents": {"predictive": 1, "budget": -1}}`)); err == nil {
+    t.Errorf("negative budget accepted")
+  }
+}
+
+func TestChooseSeed(t *testing.T) {
+  flags := func(args ...string) *flag.FlagSet {
+    fs := flag.NewFlagSet("test", flag.ContinueOnError)
+    fs.Uint64("seed", 5, "")
+    if err := fs.Parse(args); err != nil {
+      t.Fatal(err)
+    }
+    return fs
+  }
+  e := &Experiment{}
+  if _, clock := e.ChooseSeed(flags()); !clock {
+    t.Errorf("unseeded experiment did not fall back to the clock")
+  }
+  if s, clock := e.ChooseSeed(flags("-seed", "0")); s != 0 || clock {
+    t.Errorf("-seed 0 gave %d, clock %v", s, clock)
+  }
+  seed := uint64(7)
+  e.Seed = &seed
+  if s, _ := e.ChooseSeed(flags()); s != 7 {
+    t.Errorf("seeded experiment gave %d, not the flag's default", s)
+  }
+  if s, _ := e.ChooseSeed(flags("-seed", "3")); s != 3 {
+    t.Errorf("-seed 3 gave %d over the experiment's", s)
+  }
+}
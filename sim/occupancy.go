@@ -0,0 +1,106 @@
+package sim
+import "fmt"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/agents"
+
+// Occupancy says what happens when agents meet.  Agents cannot see
+// each other (see agents.Agent), so for them any policy but Stack is a
+// hazard their planning does not model.
+type Occupancy int
+
+const (
+  // Stack lets any number of agents share a cell.
+  Stack Occupancy = iota
+  // KillBoth kills every agent that ends a step on a cell shared with
+  // another agent.
+  KillBoth
+  // KillSwap is KillBoth, and also kills two agents that swap cells,
+  // passing through each other during the step.
+  KillSwap
+  // Block moves agents one at a time in population order; a move onto
+  // a cell that holds an agent at that moment is cancelled and the
+  // mover stays put.  Agents that start apart never share a cell.
+  Block
+)
+
+var occupancyNames = []string{"stack", "kill-both", "kill-swap", "block"}
+
+func (o Occupancy) String() string {
+  if o < 0 || int(o) >= len(occupancyNames) {
+    return fmt.Sprintf("Occupancy(%d)", int(o))
+  }
+  return occupancyNames[o]
+}
+
+// ParseOccupancy is the inverse of String.
+func ParseOccupancy(s string) (Occupancy, error) {
+  for i, name := range occupancyNames {
+    if name == s {
+      return Occupancy(i), nil
+    }
+  }
+  return Stack, fmt.Errorf("unknown occupancy %q", s)
+}
+
+// moveAgents applies moves[i] to agentsPop[i] under the policy and
+// returns the agents that collided with other agents.
+func moveAgents(
+    agentsPop []agents.Agent,
+    moves []substrates.Move,
+    grid *substrates.Grid2d,
+    occ Occupancy,
+) map[agents.Agent]bool {
+  if occ == Block {
+    held := make(map[substrates.Pos]int, len(agentsPop))
+    for _, ag := range agentsPop {
+      held[ag.Pos()]++
+    }
+    for i, ag := range agentsPop {
+      from := ag.Pos()
+      to := grid.Step(from, moves[i])
+      if to == from || held[to] > 0 {
+        continue
+      }
+      ag.Apply(moves[i], grid)
+      held[from]--
+      held[to]++
+    }
+    return nil
+  }
+  from := make([]substrates.Pos, len(agentsPop))
+  for i, ag := range agentsPop {
+    from[i] = ag.Pos()
+  }
+  applyMoves(agentsPop, moves, grid)
+  if occ == Stack {
+    return nil
+  }
+  collided := map[agents.Agent]bool{}
+  at := make(map[substrates.Pos][]agents.Agent, len(agentsPop))
+  for _, ag := range agentsPop {
+    at[ag.Pos()] = append(at[ag.Pos()], ag)
+  }
+  for _, here := range at {
+    if len(here) > 1 {
+      for _, ag := range here {
+        collided[ag] = true
+      }
+    }
+  }
+  if occ == KillSwap {
+    type edge struct{ from, to substrates.Pos }
+    crossed := make(map[edge]agents.Agent, len(agentsPop))
+    for i, ag := range agentsPop {
+      if from[i] != ag.Pos() {
+        crossed[edge{from[i], ag.Pos()}] = ag
+      }
+    }
+    for e, ag := range crossed {
+      if other, ok := crossed[edge{e.to, e.from}]; ok {
+        collided[ag] = true
+        collided[other] = true
+      }
+    }
+  }
+  return collided
+}
@@ -0,0 +1,100 @@
+package sim
+import "strings"
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/agents"
+
+// meet moves two agents on an empty 5x5 grid and reports which died.
+func meet(
+    occ Occupancy,
+    a, b substrates.Pos,
+    ma, mb substrates.Move,
+) (substrates.Pos, substrates.Pos, []bool) {
+  grid := substrates.NewGrid2d(5, 5)
+  rng := substrates.NewSplitMix64(1)
+  pop := []agents.Agent{
+    agents.NewReactiveAgent(1, a, rng),
+    agents.NewReactiveAgent(2, b, rng),
+  }
+  collided := moveAgents(pop, []substrates.Move{ma, mb}, grid, occ)
+  _, dead := resolveCollisions(pop, grid, collided)
+  died := []bool{false, false}
+  for _, ag := range dead {
+    died[ag.ID()-1] = true
+  }
+  return pop[0].Pos(), pop[1].Pos(), died
+}
+
+func TestOccupancy(t *testing.T) {
+  p := func(x, y int) substrates.Pos { return substrates.Pos{X: x, Y: y} }
+  E, W, S := substrates.East, substrates.West, substrates.South
+  for _, c := range []struct {
+    occ        Occupancy
+    a, b       substrates.Pos
+    ma, mb     substrates.Move
+    wantA, wantB substrates.Pos
+    died       [2]bool
+  }{
+    // same cell
+    {Stack, p(1, 1), p(3, 1), E, W, p(2, 1), p(2, 1), [2]bool{}},
+    {KillBoth, p(1, 1), p(3, 1), E, W, p(2, 1), p(2, 1), [2]bool{true, true}},
+    {KillSwap, p(1, 1), p(3, 1), E, W, p(2, 1), p(2, 1), [2]bool{true, true}},
+    {Block, p(1, 1), p(3, 1), E, W, p(2, 1), p(3, 1), [2]bool{}},
+    // swap through each other
+    {KillBoth, p(1, 1), p(2, 1), E, W, p(2, 1), p(1, 1), [2]bool{}},
+    {KillSwap, p(1, 1), p(2, 1), E, W, p(2, 1), p(1, 1), [2]bool{true, true}},
+    {Block, p(1, 1), p(2, 1), E, W, p(1, 1), p(2, 1), [2]bool{}},
+    // swap across the torus edge
+    {KillSwap, p(0, 4), p(0, 0), S, substrates.North,
+        p(0, 0), p(0, 4), [2]bool{true, true}},
+    // following: the first mover is blocked by the agent not yet moved
+    {Block, p(1, 1), p(2, 1), E, E, p(1, 1), p(3, 1), [2]bool{}},
+    {KillSwap, p(1, 1), p(2, 1), E, E, p(2, 1), p(3, 1), [2]bool{}},
+  } {
+    gotA, gotB, died := meet(c.occ, c.a, c.b, c.ma, c.mb)
+    if gotA != c.wantA || gotB != c.wantB || died[0] != c.died[0] ||
+        died[1] != c.died[1] {
+      t.Errorf("%v %v%v %v%v: got %v %v died %v, want %v %v died %v",
+          c.occ, c.a, c.ma, c.b, c.mb, gotA, gotB, died,
+          c.wantA, c.wantB, c.died)
+    }
+  }
+}
+
+func TestParseOccupancy(t *testing.T) {
+  for _, occ := range []Occupancy{Stack, KillBoth, KillSwap, Block} {
+    if got, err := ParseOccupancy(occ.String()); err != nil || got != occ {
+      t.Errorf("%v: parsed %v, %v", occ, got, err)
+    }
+  }
+  if _, err := ParseOccupancy("crush"); err == nil {
+    t.Errorf("accepted crush")
+  }
+}
+
+func TestBlockKeepsAgentsApart(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "noisy", "width": 5, "height": 5, "steps": 30,
+    "noise": 0.05, "complexity": 5, "foresight": 1,
+    "agents": {"reactive": 8, "predictive": 4}, "occupancy": "block"
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  ep := e.Record(e.Runs(3)[0])
+  for tick := 0; tick <= ep.Ticks(); tick++ {
+    seen := map[substrates.Pos]int{}
+    for _, at := range ep.Agents {
+      if tick < len(at.Path) {
+        if other, ok := seen[at.Path[tick]]; ok {
+          t.Fatalf("tick %d: agents %d and %d share %v",
+              tick, other, at.ID, at.Path[tick])
+        }
+        seen[at.Path[tick]] = at.ID
+      }
+    }
+  }
+  if ep.Result.Occupancy != "block" {
+    t.Errorf("result occupancy %q", ep.Result.Occupancy)
+  }
+}
@@ -0,0 +1,57 @@
+package sim
+import "sync"
+
+// Parallel runs fn(0) .. fn(n-1) on up to workers goroutines and hands
+// each result to emit in ID order, as soon as all lower IDs are done.
+// emit is only ever called from the caller's goroutine, so it may write
+// output without locking.  With workers <= 1 everything runs inline.
+//
+// Output is identical for any worker count as long as fn(id) depends
+// only on id; Experiment.Execute seeds each run from the run ID alone.
+func Parallel[R any](n, workers int, fn func(id int) R, emit func(id int, r R)) {
+  if workers <= 1 {
+    for id := 0; id < n; id++ {
+      emit(id, fn(id))
+    }
+    return
+  }
+  type result struct {
+    id int
+    r  R
+  }
+  ids := make(chan int)
+  results := make(chan result, workers)
+  var wg sync.WaitGroup
+  for w := 0; w < workers; w++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for id := range ids {
+        results <- result{id: id, r: fn(id)}
+      }
+    }()
+  }
+  go func() {
+    for id := 0; id < n; id++ {
+      ids <- id
+    }
+    close(ids)
+    wg.Wait()
+    close(results)
+  }()
+  // reorder: hold early finishers until their predecessors arrive
+  pending := make(map[int]R)
+  next := 0
+  for res := range results {
+    pending[res.id] = res.r
+    for {
+      r, ok := pending[next]
+      if !ok {
+        break
+      }
+      delete(pending, next)
+      emit(next, r)
+      next++
+    }
+  }
+}
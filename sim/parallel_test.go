@@ -0,0 +1,69 @@
+package sim
+import "strings"
+import "testing"
+import "time"
+
+func TestParallelEmitsInOrder(t *testing.T) {
+  const n = 40
+  var got []int
+  Parallel(n, 8,
+      func(id int) int {
+        // later IDs finish first
+        time.Sleep(time.Duration(n-id) * 100 * time.Microsecond)
+        return id * id
+      },
+      func(id int, sq int) {
+        if sq != id*id {
+          t.Errorf("run %d delivered result %d", id, sq)
+        }
+        got = append(got, id)
+      },
+  )
+  if len(got) != n {
+    t.Fatalf("emitted %d results, want %d", len(got), n)
+  }
+  for i, id := range got {
+    if id != i {
+      t.Fatalf("emit order %v is not run-ID order", got)
+    }
+  }
+}
+
+func TestSweepOutputIndependentOfWorkers(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "gameofnoise",
+    "width": 10, "height": 10, "steps": 8,
+    "noise": [0.0, 0.2],
+    "complexity": [15, 30],
+    "foresight": [1, 2],
+    "agents": {"reactive": 2, "predictive": 2, "mcts": 1, "mcts_budget": 10}
+  }`))
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  runs := e.Runs(99)
+  sweep := func(workers int) string {
+    var b strings.Builder
+    out := NewCSVResultWriter(&b)
+    Parallel(len(runs), workers,
+        func(id int) RunResult { return e.Execute(runs[id]) },
+        func(_ int, res RunResult) {
+          res.WallTime = 0
+          if err := out.Write(&res); err != nil {
+            t.Fatal(err)
+          }
+        },
+    )
+    if err := out.Close(); err != nil {
+      t.Fatal(err)
+    }
+    return b.String()
+  }
+  serial := sweep(1)
+  for _, workers := range []int{2, 4, 7} {
+    if got := sweep(workers); got != serial {
+      t.Fatalf("%d workers:\n%s\ndiffers from serial:\n%s",
+          workers, got, serial)
+    }
+  }
+}
@@ -0,0 +1,82 @@
+package sim
+import "fmt"
+import "oscarkilo.com/inteluni/substrates"
+
+// Perception decides what each agent is shown of the universe when it
+// decides.  The zero value shows every agent the whole grid as it is,
+// the universe's own grid, not a copy.
+//
+// With a Radius an agent sees only the (2·Radius+1)² window of cells
+// around it, following the grid's topology; the rest are unknown (see
+// Grid2d.Forget).  With Noise every cell it sees is misread with that
+// probability: an Empty cell as Live, any other as Empty.  Noise never
+// touches the agent's own cell, so an agent is never mistaken about
+// being alive.  Each agent gets its own observation, drawn from Rng,
+// which must be set when Noise is.
+type Perception struct {
+  Radius int      // 0: the whole grid
+  Noise  float64  // probability of misreading a seen cell
+  Rng    *substrates.SplitMix64
+}
+
+// Validate checks the radius and noise are in range.
+func (p Perception) Validate() error {
+  if p.Radius < 0 {
+    return fmt.Errorf("sensing radius %d is negative", p.Radius)
+  }
+  if p.Noise < 0 || p.Noise > 1 {
+    return fmt.Errorf("sensing noise %v outside 0..1", p.Noise)
+  }
+  return nil
+}
+
+// Full reports whether agents see the whole grid exactly.
+func (p Perception) Full() bool {
+  return p.Radius == 0 && p.Noise == 0
+}
+
+// Observe returns what an agent at pos perceives of g.
+func (p Perception) Observe(
+    g *substrates.Grid2d,
+    pos substrates.Pos,
+) *substrates.Grid2d {
+  if p.Full() {
+    return g
+  }
+  obs := g.Clone()
+  if p.Radius > 0 {
+    for y := 0; y < g.H(); y++ {
+      for x := 0; x < g.W(); x++ {
+        obs.Forget(x, y)
+      }
+    }
+    for dy := -p.Radius; dy <= p.Radius; dy++ {
+      for dx := -p.Radius; dx <= p.Radius; dx++ {
+        if x, y, ok := g.Resolve(pos.X+dx, pos.Y+dy); ok {
+          obs.SetXY(x, y, g.XY(x, y))
+        }
+      }
+    }
+  }
+  if p.Noise > 0 {
+    for y := 0; y < g.H(); y++ {
+      for x := 0; x < g.W(); x++ {
+        if !obs.KnownXY(x, y) || (x == pos.X && y == pos.Y) {
+          continue
+        }
+        if p.Rng.Float64() < p.Noise {
+          obs.SetXY(x, y, misread(obs.XY(x, y)))
+        }
+      }
+    }
+  }
+  return obs
+}
+
+// misread is what a cell in state v is mistaken for.
+func misread(v int) int {
+  if v == substrates.Empty {
+    return substrates.Live
+  }
+  return substrates.Empty
+}
@@ -0,0 +1,52 @@
+package sim
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+func TestPerceptionWindow(t *testing.T) {
+  g := substrates.NewGrid2d(8, 6)
+  g.SetXY(7, 5, substrates.Live)
+  g.SetXY(3, 3, substrates.Live)
+  if (Perception{}).Observe(g, substrates.Pos{}) != g {
+    t.Errorf("full perception copied the grid")
+  }
+  p := Perception{Radius: 1}
+  obs := p.Observe(g, substrates.Pos{X: 0, Y: 0})
+  known := 0
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      if obs.KnownXY(x, y) {
+        known++
+      }
+    }
+  }
+  // the window wraps around the torus
+  if known != 9 || !obs.KnownXY(7, 5) || obs.XY(7, 5) != substrates.Live {
+    t.Errorf("%d cells known, corner %v", known, obs.KnownXY(7, 5))
+  }
+  if obs.KnownXY(3, 3) || obs.XY(3, 3) != substrates.Empty {
+    t.Errorf("a cell out of sight is known")
+  }
+  g.SetTopology(substrates.Bounded)
+  if obs := p.Observe(g, substrates.Pos{}); obs.KnownXY(7, 5) {
+    t.Errorf("bounded window wrapped")
+  }
+  if g.Partial() {
+    t.Errorf("Observe changed the universe's grid")
+  }
+}
+
+func TestPerceptionNoise(t *testing.T) {
+  g := substrates.NewGrid2d(10, 10)
+  g.SetXY(5, 5, substrates.Live)
+  p := Perception{Noise: 1, Rng: substrates.NewSplitMix64(1)}
+  obs := p.Observe(g, substrates.Pos{X: 5, Y: 5})
+  if obs.XY(5, 5) != substrates.Live || obs.XY(0, 0) != substrates.Live {
+    t.Errorf("every cell but the agent's own should be misread")
+  }
+  if err := (Perception{Noise: 1.5}).Validate(); err == nil {
+    t.Errorf("noise 1.5 accepted")
+  }
+  if err := (Perception{Radius: -1}).Validate(); err == nil {
+    t.Errorf("negative radius accepted")
+  }
+}
@@ -0,0 +1,52 @@
+// Command record runs a single run of an experiment and writes its
+// sim.Episode as JSON to stdout.
+//
+//   go run ./sim/record -seed 7 -run 12 sim/experiments/gameofnoise.json > ep.json
+//   go run ./sim/replay ep.json
+//
+// Run IDs and seeds are those of the sweep command, so a row of a sweep
+// is recorded by passing its id and the sweep's base seed, which is the
+// row's seed minus its id.
+package main
+import "flag"
+import "fmt"
+import "os"
+import "oscarkilo.com/inteluni/sim"
+
+var seedFlag = flag.Uint64(
+    "seed", 0, "base random seed, overriding the experiment's",)
+var runFlag = flag.Int(
+    "run", 0, "ID of the run to record",)
+
+func main() {
+  flag.Usage = func() {
+    fmt.Fprintf(os.Stderr, "usage: record [flags] experiment.json\n")
+    flag.PrintDefaults()
+  }
+  flag.Parse()
+  if flag.NArg() != 1 {
+    flag.Usage()
+    os.Exit(2)
+  }
+  exp, err := sim.LoadExperiment(flag.Arg(0))
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+  // a clock seed could not be recorded again
+  seed, clock := exp.ChooseSeed(flag.CommandLine)
+  if clock {
+    fmt.Fprintln(os.Stderr, "record needs -seed or a seeded experiment")
+    os.Exit(2)
+  }
+  runs := exp.Runs(seed)
+  if *runFlag < 0 || *runFlag >= len(runs) {
+    fmt.Fprintf(os.Stderr, "run %d outside 0..%d\n", *runFlag, len(runs)-1)
+    os.Exit(2)
+  }
+  ep := exp.Record(runs[*runFlag])
+  if err := sim.WriteEpisode(os.Stdout, ep); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
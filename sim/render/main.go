@@ -0,0 +1,84 @@
+// Command render draws a recorded episode as an animated GIF, PNG
+// frames, or both.
+//
+//   go run ./sim/render -gif ep.gif ep.json
+//   go run ./sim/render -png frames/ -cell 16 ep.json
+//   go run ./sim/render -png . -tick 12 ep.json
+//
+// See export.LoadPalette for the -palette file format.
+package main
+import "flag"
+import "fmt"
+import "os"
+import "path/filepath"
+import "strings"
+import "oscarkilo.com/inteluni/export"
+import "oscarkilo.com/inteluni/sim"
+import "oscarkilo.com/inteluni/view"
+
+var gifFlag = flag.String(
+    "gif", "", "write an animated GIF to this file",)
+var pngFlag = flag.String(
+    "png", "", "write PNG frames into this directory",)
+var tickFlag = flag.Int(
+    "tick", -1, "render only this tick",)
+var cellFlag = flag.Int(
+    "cell", export.DefaultOptions.CellSize, "pixels per cell",)
+var delayFlag = flag.Duration(
+    "delay", export.DefaultOptions.Delay, "GIF time per frame",)
+var paletteFlag = flag.String(
+    "palette", "", "JSON palette file (default: export.DefaultPalette)",)
+
+func main() {
+  flag.Usage = func() {
+    fmt.Fprintf(os.Stderr,
+        "usage: render [-gif out.gif] [-png dir] [flags] episode.json\n")
+    flag.PrintDefaults()
+  }
+  flag.Parse()
+  if flag.NArg() != 1 || (*gifFlag == "" && *pngFlag == "") {
+    flag.Usage()
+    os.Exit(2)
+  }
+  ep, err := sim.LoadEpisode(flag.Arg(0))
+  if err != nil {
+    fail(err)
+  }
+  opts := export.Options{CellSize: *cellFlag, Delay: *delayFlag}
+  if *paletteFlag != "" {
+    if opts.Palette, err = export.LoadPalette(*paletteFlag); err != nil {
+      fail(err)
+    }
+  }
+  frames := view.EpisodeFrames(ep)
+  if *tickFlag >= 0 {
+    if *tickFlag >= len(frames) {
+      fail(fmt.Errorf("episode has ticks 0..%d", len(frames)-1))
+    }
+    frames = frames[*tickFlag : *tickFlag+1]
+  }
+  if *gifFlag != "" {
+    out, err := os.Create(*gifFlag)
+    if err != nil {
+      fail(err)
+    }
+    if err := export.WriteGIF(out, frames, opts); err != nil {
+      fail(err)
+    }
+    if err := out.Close(); err != nil {
+      fail(err)
+    }
+  }
+  if *pngFlag != "" {
+    prefix := strings.TrimSuffix(
+        strings.TrimSuffix(filepath.Base(flag.Arg(0)), ".json"), ".episode")
+    if _, err := export.WritePNGs(*pngFlag, prefix, frames, opts); err != nil {
+      fail(err)
+    }
+  }
+}
+
+func fail(err error) {
+  fmt.Fprintln(os.Stderr, err)
+  os.Exit(1)
+}
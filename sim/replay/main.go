@@ -0,0 +1,36 @@
+// Command replay re-runs recorded episodes from their experiment and
+// seed and checks that they reproduce the log exactly.  For each one
+// that diverges it names the first differing tick, and it exits with
+// status 1 if any did.
+//
+//   go run ./sim/replay sim/testdata/*.episode.json
+package main
+import "fmt"
+import "os"
+import "oscarkilo.com/inteluni/sim"
+
+func main() {
+  if len(os.Args) < 2 {
+    fmt.Fprintf(os.Stderr, "usage: replay episode.json...\n")
+    os.Exit(2)
+  }
+  failed := false
+  for _, path := range os.Args[1:] {
+    want, err := sim.LoadEpisode(path)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    got := want.Experiment.Record(want.Run)
+    if err := sim.CompareEpisodes(want, got); err != nil {
+      fmt.Printf("%s: FAIL: %v\n", path, err)
+      failed = true
+      continue
+    }
+    fmt.Printf("%s: ok, %d ticks, %d agents\n",
+        path, want.Ticks(), len(want.Agents))
+  }
+  if failed {
+    os.Exit(1)
+  }
+}
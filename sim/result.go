@@ -0,0 +1,391 @@
+package sim
+import "bufio"
+import "bytes"
+import "encoding/csv"
+import "encoding/gob"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "io"
+import "math"
+import "sort"
+import "strconv"
+import "time"
+import "oscarkilo.com/inteluni/metrics"
+
+// RunResult is everything known about one finished run: enough to
+// reproduce it (Seed plus the parameters) and what it measured.
+type RunResult struct {
+  ID         int           `json:"id"`
+  Seed       uint64        `json:"seed"`
+  Universe   string        `json:"universe"`
+  Rule       string        `json:"rule,omitempty"`
+  Topology   string        `json:"topology"`
+  Width      int           `json:"width"`
+  Height     int           `json:"height"`
+  Steps      int           `json:"steps"`
+  Noise      float64       `json:"noise"`
+  Complexity int           `json:"complexity"`
+  Foresight  int           `json:"foresight"`
+  Sensing    int           `json:"sensing,omitempty"`  // 0: whole grid
+  SensingNoise float64     `json:"sensing_noise,omitempty"`
+  MCTSBudget int           `json:"mcts_budget,omitempty"`
+  Budget     int           `json:"budget,omitempty"`  // predictive evolver calls per decision
+  DeadlineMs float64       `json:"deadline_ms,omitempty"`  // wall clock per decision
+  Risk       string        `json:"risk"`
+  Occupancy  string        `json:"occupancy"`
+  Spawned    AgentCounts   `json:"spawned"`
+  Collisions AgentCounts   `json:"collisions"`
+  StepsRun   int           `json:"steps_run"`  // < Steps if all agents died
+  Lifetimes  []AgentLifetime `json:"lifetimes"`  // in spawn order
+  MeanLife   KindStats     `json:"mean_life"`    // restricted to Steps ticks
+  MedianLife KindStats     `json:"median_life"`  // NaN: half never died
+  Calls      KindStats     `json:"calls"`  // evolver calls per decision
+  K          float64       `json:"K"`
+  Estimates  map[string]float64 `json:"estimates,omitempty"`  // by estimator name
+  TauL       float64       `json:"TauL"`
+  TauLRuns   int           `json:"TauL_runs"`
+  TauLDivergent int        `json:"TauL_divergent"`  // runs with λ > 0
+  Dynamics   string        `json:"dynamics,omitempty"`  // metrics.DynamicsClass, if analysed
+  DerridaSlope float64     `json:"derrida_slope,omitempty"`
+  Damage     float64       `json:"damage,omitempty"`  // steady-state damage fraction
+  ModelAccuracy float64    `json:"model_accuracy,omitempty"`  // of model agents' world models
+  TTHitRate  float64       `json:"tt_hit_rate,omitempty"`  // of planners' transposition tables
+  WallTime   time.Duration `json:"wall_time_ns"`
+}
+
+// AgentCounts counts agents by kind.
+type AgentCounts struct {
+  Reactive   int `json:"reactive"`
+  Predictive int `json:"predictive"`
+  MCTS       int `json:"mcts"`
+  Learning   int `json:"learning,omitempty"`
+  Model      int `json:"model,omitempty"`
+}
+
+// KindStats holds one statistic per agent kind, 0 for kinds that were
+// not spawned.  A statistic that is undefined for a kind is NaN, which
+// JSON writes as null.
+type KindStats struct {
+  Reactive   float64 `json:"reactive"`
+  Predictive float64 `json:"predictive"`
+  MCTS       float64 `json:"mcts"`
+  Learning   float64 `json:"learning,omitempty"`
+  Model      float64 `json:"model,omitempty"`
+}
+
+// kindStatsJSON is KindStats as JSON writes it.
+type kindStatsJSON struct {
+  Reactive   nullFloat `json:"reactive"`
+  Predictive nullFloat `json:"predictive"`
+  MCTS       nullFloat `json:"mcts"`
+  Learning   nullFloat `json:"learning,omitempty"`
+  Model      nullFloat `json:"model,omitempty"`
+}
+
+func (s KindStats) MarshalJSON() ([]byte, error) {
+  return json.Marshal(kindStatsJSON{
+    nullFloat(s.Reactive), nullFloat(s.Predictive), nullFloat(s.MCTS),
+    nullFloat(s.Learning), nullFloat(s.Model),
+  })
+}
+
+func (s *KindStats) UnmarshalJSON(data []byte) error {
+  var j kindStatsJSON
+  if err := json.Unmarshal(data, &j); err != nil {
+    return err
+  }
+  *s = KindStats{
+    float64(j.Reactive), float64(j.Predictive), float64(j.MCTS),
+    float64(j.Learning), float64(j.Model),
+  }
+  return nil
+}
+
+// nullFloat is a float64 that JSON writes as null when it is NaN.
+type nullFloat float64
+
+func (f nullFloat) MarshalJSON() ([]byte, error) {
+  if math.IsNaN(float64(f)) {
+    return []byte("null"), nil
+  }
+  return json.Marshal(float64(f))
+}
+
+func (f *nullFloat) UnmarshalJSON(data []byte) error {
+  if string(data) == "null" {
+    *f = nullFloat(math.NaN())
+    return nil
+  }
+  return json.Unmarshal(data, (*float64)(f))
+}
+
+// sameResult reports whether a and b are equal, with NaN statistics
+// equal to each other, as reflect.DeepEqual would have them otherwise.
+func sameResult(a, b RunResult) bool {
+  ja, err := json.Marshal(a)
+  if err != nil {
+    return false
+  }
+  jb, err := json.Marshal(b)
+  return err == nil && bytes.Equal(ja, jb)
+}
+
+// AgentLifetime is how long one agent lived: until its death tick, or
+// censored at the end of the run if it survived.  Model agents also
+// report how many cells their world model predicted, and how many of
+// those it got right.  Planners count the steps of the world they
+// simulated to decide, and predictive planners how often their
+// transposition table was probed and hit.
+type AgentLifetime struct {
+  ID    int    `json:"id"`
+  Kind  string `json:"kind"`
+  Ticks int    `json:"ticks"`
+  Died  bool   `json:"died"`
+  Predicted int `json:"predicted,omitempty"`
+  Correct   int `json:"correct,omitempty"`
+  EvolverCalls int `json:"evolver_calls,omitempty"`  // over its life
+  TTProbes  int `json:"tt_probes,omitempty"`
+  TTHits    int `json:"tt_hits,omitempty"`
+}
+
+// Lives returns the lifetimes of the agents of one kind.
+func (r *RunResult) Lives(kind string) []metrics.Lifetime {
+  var lives []metrics.Lifetime
+  for _, l := range r.Lifetimes {
+    if l.Kind == kind {
+      lives = append(lives, metrics.Lifetime{Ticks: l.Ticks, Died: l.Died})
+    }
+  }
+  return lives
+}
+
+// Survival is the Kaplan–Meier survival curve of one kind of agent.
+func (r *RunResult) Survival(kind string) metrics.SurvivalCurve {
+  return metrics.KaplanMeier(r.Lives(kind))
+}
+
+// Hazard is the per-tick hazard of one kind of agent, for ticks
+// 1..StepsRun.
+func (r *RunResult) Hazard(kind string) []float64 {
+  return metrics.Hazard(r.Lives(kind), r.StepsRun)
+}
+
+// ResultWriter streams RunResults in some file format.  Close flushes
+// buffered output; it does not close the underlying writer.
+type ResultWriter interface {
+  Write(r *RunResult) error
+  Close() error
+}
+
+// ResultFormats lists the formats NewResultWriter accepts.
+var ResultFormats = []string{"csv", "jsonl", "gob"}
+
+// NewResultWriter returns a writer for "csv", "jsonl" or "gob".
+func NewResultWriter(format string, w io.Writer) (ResultWriter, error) {
+  switch format {
+    case "csv":
+      return NewCSVResultWriter(w), nil
+    case "jsonl":
+      return NewJSONLResultWriter(w), nil
+    case "gob":
+      return NewGobResultWriter(w), nil
+    default:
+      return nil, fmt.Errorf("unknown result format %q", format)
+  }
+}
+
+// csvHeader keeps the column names of the older data/run_*.csv files
+// (noise, complexity, foresight, K, TauL, C_react, C_pred) so that
+// data/graph_0.py reads both.
+var csvHeader = []string{
+  "id", "seed", "universe", "rule", "topology",
+  "width", "height", "steps",
+  "noise", "complexity", "foresight", "mcts_budget", "budget", "deadline_ms",
+  "risk", "occupancy",
+  "sensing", "sensing_noise",
+  "N_react", "N_pred", "N_mcts", "N_learn", "N_model",
+  "steps_run", "K", "TauL", "TauL_runs", "TauL_divergent",
+  "dynamics", "derrida_slope", "damage",
+  "C_react", "C_pred", "C_mcts", "C_learn", "C_model",
+  "life_react", "life_pred", "life_mcts", "life_learn", "life_model",
+  "median_react", "median_pred", "median_mcts", "median_learn",
+  "median_model", "model_accuracy",
+  "calls_react", "calls_pred", "calls_mcts", "calls_learn", "calls_model",
+  "tt_hit_rate", "wall_ms",
+}
+
+type csvResultWriter struct {
+  w         *csv.Writer
+  header    bool
+  estimates []string  // estimator columns, from the first result
+}
+
+// NewCSVResultWriter writes a header line, then one row per result.
+// Floats are written in their shortest exact form.  Lifetimes do not
+// fit in a row and are left out; the other formats keep them.  The
+// Derrida columns are empty for runs that were not analysed, a median
+// lifetime for a kind of which half never died, and model_accuracy for
+// runs without model agents.  Each complexity estimate of the first
+// result gets a column K_<name>, and later results fill the same
+// columns.
+func NewCSVResultWriter(w io.Writer) ResultWriter {
+  return &csvResultWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvResultWriter) Write(r *RunResult) error {
+  if !cw.header {
+    for name := range r.Estimates {
+      cw.estimates = append(cw.estimates, name)
+    }
+    sort.Strings(cw.estimates)
+    if err := cw.writeHeader(); err != nil {
+      return err
+    }
+  }
+  itoa := strconv.Itoa
+  ftoa := func(f float64) string {
+    return strconv.FormatFloat(f, 'g', -1, 64)
+  }
+  // empty, not 0, for runs without a Derrida analysis
+  derrida := func(f float64) string {
+    if r.Dynamics == "" {
+      return ""
+    }
+    return ftoa(f)
+  }
+  // empty, not NaN, for kinds of which half never died
+  median := func(f float64) string {
+    if math.IsNaN(f) {
+      return ""
+    }
+    return ftoa(f)
+  }
+  accuracy := ""
+  if r.Spawned.Model > 0 {
+    accuracy = ftoa(r.ModelAccuracy)
+  }
+  row := []string{
+    itoa(r.ID), strconv.FormatUint(r.Seed, 10),
+    r.Universe, r.Rule, r.Topology,
+    itoa(r.Width), itoa(r.Height), itoa(r.Steps),
+    ftoa(r.Noise), itoa(r.Complexity), itoa(r.Foresight),
+    itoa(r.MCTSBudget), itoa(r.Budget), ftoa(r.DeadlineMs),
+    r.Risk, r.Occupancy,
+    itoa(r.Sensing), ftoa(r.SensingNoise),
+    itoa(r.Spawned.Reactive), itoa(r.Spawned.Predictive),
+    itoa(r.Spawned.MCTS), itoa(r.Spawned.Learning), itoa(r.Spawned.Model),
+    itoa(r.StepsRun), ftoa(r.K), ftoa(r.TauL),
+    itoa(r.TauLRuns), itoa(r.TauLDivergent),
+    r.Dynamics, derrida(r.DerridaSlope), derrida(r.Damage),
+    itoa(r.Collisions.Reactive), itoa(r.Collisions.Predictive),
+    itoa(r.Collisions.MCTS), itoa(r.Collisions.Learning),
+    itoa(r.Collisions.Model),
+    ftoa(r.MeanLife.Reactive), ftoa(r.MeanLife.Predictive),
+    ftoa(r.MeanLife.MCTS), ftoa(r.MeanLife.Learning),
+    ftoa(r.MeanLife.Model),
+    median(r.MedianLife.Reactive), median(r.MedianLife.Predictive),
+    median(r.MedianLife.MCTS), median(r.MedianLife.Learning),
+    median(r.MedianLife.Model), accuracy,
+    ftoa(r.Calls.Reactive), ftoa(r.Calls.Predictive), ftoa(r.Calls.MCTS),
+    ftoa(r.Calls.Learning), ftoa(r.Calls.Model), ftoa(r.TTHitRate),
+    ftoa(float64(r.WallTime) / float64(time.Millisecond)),
+  }
+  for _, name := range cw.estimates {
+    v, ok := r.Estimates[name]
+    if !ok {
+      row = append(row, "")
+      continue
+    }
+    row = append(row, ftoa(v))
+  }
+  return cw.w.Write(row)
+}
+
+func (cw *csvResultWriter) writeHeader() error {
+  header := append([]string(nil), csvHeader...)
+  for _, name := range cw.estimates {
+    header = append(header, "K_" + name)
+  }
+  cw.header = true
+  return cw.w.Write(header)
+}
+
+func (cw *csvResultWriter) Close() error {
+  if !cw.header {
+    // an empty sweep still gets its header
+    if err := cw.writeHeader(); err != nil {
+      return err
+    }
+  }
+  cw.w.Flush()
+  return cw.w.Error()
+}
+
+type jsonlResultWriter struct {
+  buf *bufio.Writer
+  enc *json.Encoder
+}
+
+// NewJSONLResultWriter writes one JSON object per line.
+func NewJSONLResultWriter(w io.Writer) ResultWriter {
+  buf := bufio.NewWriter(w)
+  return &jsonlResultWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (jw *jsonlResultWriter) Write(r *RunResult) error {
+  return jw.enc.Encode(r)
+}
+
+func (jw *jsonlResultWriter) Close() error {
+  return jw.buf.Flush()
+}
+
+type gobResultWriter struct {
+  buf *bufio.Writer
+  enc *gob.Encoder
+}
+
+// NewGobResultWriter writes a gob stream of RunResult values, read
+// back by ReadResults.
+func NewGobResultWriter(w io.Writer) ResultWriter {
+  buf := bufio.NewWriter(w)
+  return &gobResultWriter{buf: buf, enc: gob.NewEncoder(buf)}
+}
+
+func (gw *gobResultWriter) Write(r *RunResult) error {
+  return gw.enc.Encode(r)
+}
+
+func (gw *gobResultWriter) Close() error {
+  return gw.buf.Flush()
+}
+
+// ReadResults reads back a stream written in the "jsonl" or "gob"
+// format.
+func ReadResults(format string, r io.Reader) ([]RunResult, error) {
+  var decode func(*RunResult) error
+  switch format {
+    case "jsonl":
+      dec := json.NewDecoder(r)
+      dec.DisallowUnknownFields()
+      decode = func(res *RunResult) error { return dec.Decode(res) }
+    case "gob":
+      dec := gob.NewDecoder(r)
+      decode = func(res *RunResult) error { return dec.Decode(res) }
+    default:
+      return nil, fmt.Errorf("cannot read result format %q", format)
+  }
+  var results []RunResult
+  for {
+    var res RunResult
+    err := decode(&res)
+    if errors.Is(err, io.EOF) {
+      return results, nil
+    }
+    if err != nil {
+      return results, err
+    }
+    results = append(results, res)
+  }
+}
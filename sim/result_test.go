@@ -0,0 +1,177 @@
+package sim
+import "bytes"
+import "math"
+import "strings"
+import "testing"
+import "time"
+
+func sampleResults() []RunResult {
+  return []RunResult{
+    {
+      ID: 0, Seed: 42, Universe: "gameofnoise", Topology: "torus",
+      Width: 10, Height: 8, Steps: 20, Noise: 0.15, Complexity: 30,
+      Foresight: 2, Risk: "worst", Occupancy: "stack",
+      Spawned:    AgentCounts{Reactive: 3, Predictive: 2},
+      Collisions: AgentCounts{Reactive: 1},
+      StepsRun: 20, K: 0.123456789, TauL: 7, TauLRuns: 11, TauLDivergent: 4,
+      Dynamics: "chaotic", DerridaSlope: 1.25, Damage: 0.125,
+      WallTime: 1500 * time.Microsecond,
+      Lifetimes: []AgentLifetime{
+        {ID: 1, Kind: "reactive", Ticks: 4, Died: true},
+        {ID: 2, Kind: "predictive", Ticks: 20},
+      },
+      MeanLife:   KindStats{Reactive: 15.5, Predictive: 20},
+      MedianLife: KindStats{Reactive: 4, Predictive: math.NaN()},
+    },
+    {
+      ID: 1, Seed: 43, Universe: "lifelike", Rule: "B36/S23",
+      Topology: "klein", Width: 10, Height: 8, Steps: 20, Complexity: 30,
+      Foresight: 3, MCTSBudget: 50, Risk: "cvar:0.25",
+      Occupancy: "kill-swap",
+      Spawned:    AgentCounts{MCTS: 4},
+      Collisions: AgentCounts{MCTS: 4},
+      StepsRun: 6, K: 0.5, TauL: 50,
+    },
+  }
+}
+
+func TestResultRoundTrip(t *testing.T) {
+  for _, format := range []string{"jsonl", "gob"} {
+    var buf bytes.Buffer
+    w, err := NewResultWriter(format, &buf)
+    if err != nil {
+      t.Fatal(err)
+    }
+    want := sampleResults()
+    for i := range want {
+      if err := w.Write(&want[i]); err != nil {
+        t.Fatalf("%s: %v", format, err)
+      }
+    }
+    if err := w.Close(); err != nil {
+      t.Fatalf("%s: %v", format, err)
+    }
+    got, err := ReadResults(format, &buf)
+    if err != nil {
+      t.Fatalf("%s: %v", format, err)
+    }
+    same := len(got) == len(want)
+    for i := 0; same && i < len(got); i++ {
+      same = sameResult(got[i], want[i])
+    }
+    if !same {
+      t.Errorf("%s round trip:\n got %+v\nwant %+v", format, got, want)
+    }
+  }
+}
+
+func TestCSVResultWriter(t *testing.T) {
+  var buf bytes.Buffer
+  w := NewCSVResultWriter(&buf)
+  for _, res := range sampleResults() {
+    if err := w.Write(&res); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+  lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+  if len(lines) != 3 {
+    t.Fatalf("got %d lines, want header + 2:\n%s", len(lines), buf.String())
+  }
+  if lines[0] != strings.Join(csvHeader, ",") {
+    t.Errorf("header %q", lines[0])
+  }
+  want := "0,42,gameofnoise,,torus,10,8,20,0.15,30,2,0,0,0,worst,stack,0,0," +
+      "3,2,0,0,0,20,0.123456789,7,11,4,chaotic,1.25,0.125," +
+      "1,0,0,0,0,15.5,20,0,0,0,4,,0,0,0,,0,0,0,0,0,0,1.5"
+  if lines[1] != want {
+    t.Errorf("row\n got %s\nwant %s", lines[1], want)
+  }
+  // the second run was not analysed
+  if !strings.Contains(lines[2], ",50,0,0,,,,0,0,4,0,0,") {
+    t.Errorf("row %s", lines[2])
+  }
+}
+
+func TestCSVResultWriterEmpty(t *testing.T) {
+  var buf bytes.Buffer
+  w := NewCSVResultWriter(&buf)
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+  if got := buf.String(); got != strings.Join(csvHeader, ",") + "\n" {
+    t.Errorf("empty sweep wrote %q", got)
+  }
+}
+
+func TestExecuteRecordsRun(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "lifelike", "rule": "B36/S23", "topology": "bounded",
+    "width": 12, "height": 12, "steps": 10,
+    "complexity": 20, "foresight": 2,
+    "agents": {"reactive": 3, "predictive": 1}
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  r := e.Runs(5)[0]
+  res := e.Execute(r)
+  if res.Seed != 5 || res.Universe != "lifelike" || res.Rule != "B36/S23" ||
+      res.Topology != "bounded" || res.Steps != 10 || res.Foresight != 2 {
+    t.Errorf("parameters not recorded: %+v", res)
+  }
+  if res.Spawned != (AgentCounts{Reactive: 3, Predictive: 1}) {
+    t.Errorf("spawned %+v", res.Spawned)
+  }
+  if res.StepsRun < 1 || res.StepsRun > 10 {
+    t.Errorf("steps run %d", res.StepsRun)
+  }
+  again := e.Execute(r)
+  res.WallTime, again.WallTime = 0, 0
+  if !sameResult(res, again) {
+    t.Errorf("same run gave\n%+v\n%+v", res, again)
+  }
+}
+
+func TestCSVEstimateColumns(t *testing.T) {
+  e, err := ParseExperiment(strings.NewReader(`{
+    "universe": "gameoflife", "width": 8, "height": 8, "steps": 5,
+    "complexity": [20, 40], "foresight": 1, "agents": {"reactive": 1},
+    "estimators": ["lz76", "block:2x2x1"]
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  var buf bytes.Buffer
+  w := NewCSVResultWriter(&buf)
+  for _, r := range e.Runs(1) {
+    res := e.Execute(r)
+    if len(res.Estimates) != 2 || res.Estimates["lz76"] <= 0 {
+      t.Errorf("estimates %v", res.Estimates)
+    }
+    if err := w.Write(&res); err != nil {
+      t.Fatal(err)
+    }
+  }
+  w.Close()
+  lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+  if !strings.HasSuffix(lines[0], ",wall_ms,K_block:2x2x1,K_lz76") {
+    t.Errorf("header %q", lines[0])
+  }
+  for _, line := range lines[1:] {
+    if n := strings.Count(line, ","); n != strings.Count(lines[0], ",") {
+      t.Errorf("row %q has %d commas", line, n)
+    }
+  }
+  for _, bad := range []string{`["zip"]`, `["lz76", "lz76"]`} {
+    _, err := ParseExperiment(strings.NewReader(`{
+      "universe": "gameoflife", "width": 8, "height": 8, "steps": 5,
+      "complexity": 20, "foresight": 1, "agents": {"reactive": 1},
+      "estimators": ` + bad + `}`))
+    if err == nil {
+      t.Errorf("accepted estimators %s", bad)
+    }
+  }
+}
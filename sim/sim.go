@@ -1,24 +1,73 @@
 package sim
-import "fmt"
+import "context"
+import "math"
+import "time"
 import "oscarkilo.com/inteluni/substrates"
 import "oscarkilo.com/inteluni/universes"
 import "oscarkilo.com/inteluni/agents"
 import "oscarkilo.com/inteluni/metrics"
-import "sync"
+
+// Tick describes one completed step of a simulation.
+type Tick struct {
+  T      int                 // 1 for the first step
+  Grid   *substrates.Grid2d  // the universe after the step
+  Agents []agents.Agent      // agents alive at the start of the step
+  Moves  []substrates.Move   // Moves[i] is the move Agents[i] chose
+  Dead   []agents.Agent      // agents that died in this step
+}
+
+// Options tune Simulate.  The zero value simulates plainly.
+type Options struct {
+  // Observer, if set, is called after every step.  It must not keep
+  // Tick.Grid past the call without cloning it.
+  Observer func(Tick)
+  // Occupancy decides what happens when agents meet; default Stack.
+  Occupancy Occupancy
+  // Perception decides what agents see; default everything.
+  Perception Perception
+  // Deadline, if positive, bounds the wall-clock time of each decision
+  // of an agents.ContextAgent.  Runs become irreproducible.
+  Deadline time.Duration
+}
 
 func SimulateSteps(
     u universes.Universe,
     agentsPop *[]agents.Agent,
     stepsPerRun int,
+) []*substrates.Grid2d {
+  return Simulate(u, agentsPop, stepsPerRun, Options{})
+}
+
+// Simulate advances u and the agents for up to stepsPerRun steps,
+// stopping early once every agent is dead, and returns the frames: the
+// initial grid and the grid after each step.  *agentsPop is left
+// holding the survivors.
+func Simulate(
+    u universes.Universe,
+    agentsPop *[]agents.Agent,
+    stepsPerRun int,
+    opts Options,
 ) []*substrates.Grid2d {
   frames := make([]*substrates.Grid2d, 0, stepsPerRun+1,)
   frames = append(frames, u.Grid().Clone(),)
   for step := 0; step < stepsPerRun; step++ {
-    moves := collectMoves(u, *agentsPop)
+    moves := collectMoves(u, *agentsPop, opts)
     u.Advance()
     frames = append(frames, u.Grid().Clone(),)
-    applyMoves(*agentsPop, moves, u.Grid())
-    *agentsPop, _ = resolveCollisions(*agentsPop, u.Grid())
+    movers := *agentsPop
+    collided := moveAgents(movers, moves, u.Grid(), opts.Occupancy)
+    var dead []agents.Agent
+    *agentsPop, dead = resolveCollisions(movers, u.Grid(), collided)
+    learn(movers, dead, u.Grid())
+    if opts.Observer != nil {
+      opts.Observer(Tick{
+        T:      step + 1,
+        Grid:   u.Grid(),
+        Agents: movers,
+        Moves:  moves,
+        Dead:   dead,
+      })
+    }
     if len(*agentsPop) == 0 {
       break
     }
@@ -26,15 +75,37 @@ func SimulateSteps(
   return frames
 }
 
+// learn tells every agents.Learner among movers how its move turned
+// out.
+func learn(movers, dead []agents.Agent, grid *substrates.Grid2d) {
+  died := make(map[agents.Agent]bool, len(dead))
+  for _, ag := range dead {
+    died[ag] = true
+  }
+  for _, ag := range movers {
+    if l, ok := ag.(agents.Learner); ok {
+      l.Outcome(grid, died[ag])
+    }
+  }
+}
+
 func collectMoves(
     u universes.Universe,
     agentsPop []agents.Agent,
+    opts Options,
 ) []substrates.Move {
   moves := make([]substrates.Move, len(agentsPop),)
   evolver := u.MakeEvolver()
   det := u.Deterministic()
   for i, ag := range agentsPop {
-    moves[i] = ag.Decide(u.Grid(), evolver, det,)
+    view := opts.Perception.Observe(u.Grid(), ag.Pos())
+    if ca, ok := ag.(agents.ContextAgent); ok && opts.Deadline > 0 {
+      ctx, cancel := context.WithTimeout(context.Background(), opts.Deadline)
+      moves[i] = ca.DecideContext(ctx, view, evolver, det)
+      cancel()
+      continue
+    }
+    moves[i] = ag.Decide(view, evolver, det,)
   }
   return moves
 }
@@ -49,59 +120,174 @@ func applyMoves(
   }
 }
 
+// resolveCollisions splits agents into survivors and the dead: those on
+// lethal cells and those in collided.
 func resolveCollisions(
   agentsPop []agents.Agent,
   grid *substrates.Grid2d,
+  collided map[agents.Agent]bool,
 ) ([]agents.Agent, []agents.Agent) {
   survivors := make([]agents.Agent, 0, len(agentsPop))
   dead := make([]agents.Agent, 0, len(agentsPop))
   for _, ag := range agentsPop {
     pos := ag.Pos()
-    if grid.XY(pos.X, pos.Y) == 1 {
+    if substrates.Lethal(grid.XY(pos.X, pos.Y)) {
       // obstacle death: agent moved into an obstacle
       dead = append(dead, ag)
       continue
     }
+    if collided[ag] {
+      // agent collision, see Occupancy
+      dead = append(dead, ag)
+      continue
+    }
     survivors = append(survivors, ag)
   }
   return survivors, dead
 }
 
-var headerOnce sync.Once
+// Lifetimes lists how long each spawned agent lived, given the tick
+// each dead agent died (by ID) and the number of ticks run.
+func Lifetimes(
+    spawned []agents.Agent,
+    deathTicks map[int]int,
+    ticks int,
+) []AgentLifetime {
+  lives := make([]AgentLifetime, len(spawned))
+  for i, ag := range spawned {
+    t, died := deathTicks[ag.ID()]
+    if !died {
+      t = ticks
+    }
+    lives[i] = AgentLifetime{
+      ID:    ag.ID(),
+      Kind:  agents.Kind(ag),
+      Ticks: t,
+      Died:  died,
+    }
+    if m, ok := ag.(*agents.ModelAgent); ok {
+      lives[i].Predicted = m.Model().Predicted()
+      lives[i].Correct = m.Model().Correct()
+    }
+    if c, ok := ag.(interface{ EvolverCalls() int }); ok {
+      lives[i].EvolverCalls = c.EvolverCalls()
+    }
+    if p, ok := ag.(interface{ TableStats() agents.TableStats }); ok {
+      s := p.TableStats()
+      lives[i].TTProbes, lives[i].TTHits = s.Probes, s.Hits
+    }
+  }
+  return lives
+}
+
+// ReportOptions choose the metrics Report computes.  The zero value
+// computes K and TauL as they always were.
+type ReportOptions struct {
+  Estimators []metrics.ComplexityEstimator  // more complexity measures
+  TauL       metrics.TauLOptions
+  Derrida    *metrics.DerridaOptions  // nil: no Derrida analysis
+}
 
+// Report completes res, which carries the run's identity, parameters
+// and spawned population, with the outcome measured from frames and the
+// agents' lifetimes.  TauL, then Derrida, draw from rng.  It touches no
+// shared state, so runs may be reported from any goroutine.
 func Report(
-    id int,
+    res RunResult,
     frames []*substrates.Grid2d,
     univ universes.Universe,
-    noise float64,
-    complexity int,
-    foresight int,
-    originalNumReactive int,
-    originalNumPredictive int,
-    agentsPop []agents.Agent,
+    lives []AgentLifetime,
     rng *substrates.SplitMix64,
-) {
-  headerOnce.Do(func() {
-    fmt.Printf("noise,complexity,foresight,K,TauL,C_react,C_pred\n")
-  })
-  var reactCount, predCount int
-  for _, ag := range agentsPop {
-    switch ag.(type) {
-      case *agents.ReactiveAgent:
-        reactCount++
-      case *agents.PredictiveAgent:
-        predCount++
-      default:
-        panic("unknown agent type in report")
+    opts ReportOptions,
+) RunResult {
+  res.StepsRun = len(frames) - 1
+  res.K = metrics.KolmogorovProxy(frames)
+  if len(opts.Estimators) > 0 {
+    res.Estimates = make(map[string]float64, len(opts.Estimators))
+    for _, est := range opts.Estimators {
+      res.Estimates[est.Name()] = est.Estimate(frames)
     }
   }
-  K := metrics.KolmogorovProxy(frames)
-  tau := metrics.TauL(univ, rng)
-  collisionsReactive := originalNumReactive - reactCount
-  collisionsPredictive := originalNumPredictive - predCount
-  fmt.Printf(
-      "%0.2f,%d,%d,%0.3f,%0.3f,%d,%d\n",
-      noise, complexity, foresight, K, tau,
-      collisionsReactive, collisionsPredictive,
-  )
+  tau := metrics.TauLDiagnose(univ, rng, opts.TauL)
+  res.TauL = tau.Tau
+  res.TauLRuns = len(tau.Runs)
+  res.TauLDivergent = tau.Divergent
+  if opts.Derrida != nil {
+    d := metrics.Derrida(univ, rng, *opts.Derrida)
+    res.Dynamics = d.Class.String()
+    res.DerridaSlope = d.Slope
+    res.Damage = d.Damage
+  }
+  res.Lifetimes = lives
+  res.Collisions = AgentCounts{}
+  predicted, correct := 0, 0
+  probes, hits := 0, 0
+  for _, l := range lives {
+    predicted += l.Predicted
+    correct += l.Correct
+    probes += l.TTProbes
+    hits += l.TTHits
+    if !l.Died {
+      continue
+    }
+    switch l.Kind {
+      case agents.KindReactive:
+        res.Collisions.Reactive++
+      case agents.KindPredictive:
+        res.Collisions.Predictive++
+      case agents.KindMCTS:
+        res.Collisions.MCTS++
+      case agents.KindLearning:
+        res.Collisions.Learning++
+      case agents.KindModel:
+        res.Collisions.Model++
+    }
+  }
+  if predicted > 0 {
+    res.ModelAccuracy = float64(correct) / float64(predicted)
+  }
+  if probes > 0 {
+    res.TTHitRate = float64(hits) / float64(probes)
+  }
+  stats := func(kind string) (float64, float64) {
+    lives := res.Lives(kind)
+    if len(lives) == 0 {
+      return 0, 0
+    }
+    curve := metrics.KaplanMeier(lives)
+    median, ok := curve.Median()
+    if !ok {
+      return curve.RestrictedMean(res.Steps), math.NaN()
+    }
+    return curve.RestrictedMean(res.Steps), float64(median)
+  }
+  res.MeanLife.Reactive, res.MedianLife.Reactive = stats(agents.KindReactive)
+  res.MeanLife.Predictive, res.MedianLife.Predictive =
+      stats(agents.KindPredictive)
+  res.MeanLife.MCTS, res.MedianLife.MCTS = stats(agents.KindMCTS)
+  res.MeanLife.Learning, res.MedianLife.Learning =
+      stats(agents.KindLearning)
+  res.MeanLife.Model, res.MedianLife.Model = stats(agents.KindModel)
+  // an agent decides once per tick it lives
+  calls := func(kind string) float64 {
+    n, decisions := 0, 0
+    for _, l := range lives {
+      if l.Kind == kind {
+        n += l.EvolverCalls
+        decisions += l.Ticks
+      }
+    }
+    if decisions == 0 {
+      return 0
+    }
+    return float64(n) / float64(decisions)
+  }
+  res.Calls = KindStats{
+    Reactive:   calls(agents.KindReactive),
+    Predictive: calls(agents.KindPredictive),
+    MCTS:       calls(agents.KindMCTS),
+    Learning:   calls(agents.KindLearning),
+    Model:      calls(agents.KindModel),
+  }
+  return res
 }
@@ -0,0 +1,91 @@
+// Command sweep runs the experiment described by a JSON file and
+// writes one sim.RunResult per run to stdout, in run-ID order whatever
+// the number of workers.  See sim.Experiment for the experiment format
+// and sim/experiments for examples.
+//
+//   go run ./sim/sweep -seed 7 sim/experiments/gameofnoise.json
+//   go run ./sim/sweep -format jsonl -walltime sim/experiments/noisy.json
+//
+// Without -walltime the wall_ms column is zero, so the output is
+// byte-identical for a given seed.
+package main
+import "flag"
+import "fmt"
+import "os"
+import "runtime"
+import "runtime/pprof"
+import "strings"
+import "oscarkilo.com/inteluni/sim"
+
+var seedFlag = flag.Uint64(
+    "seed", 0, "random seed, overriding the experiment's (default: clock)",)
+var workersFlag = flag.Int(
+    "workers", runtime.NumCPU(), "runs to execute concurrently",)
+var formatFlag = flag.String(
+    "format", "csv",
+    "output format: " + strings.Join(sim.ResultFormats, ", "),)
+var wallTimeFlag = flag.Bool(
+    "walltime", false, "record each run's wall time",)
+var cpuProfileFlag = flag.String(
+    "cpuprofile", "", "write a CPU profile to this file",)
+
+func main() {
+  flag.Usage = func() {
+    fmt.Fprintf(os.Stderr, "usage: sweep [flags] experiment.json\n")
+    flag.PrintDefaults()
+  }
+  flag.Parse()
+  if flag.NArg() != 1 {
+    flag.Usage()
+    os.Exit(2)
+  }
+  exp, err := sim.LoadExperiment(flag.Arg(0))
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+  out, err := sim.NewResultWriter(*formatFlag, os.Stdout)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(2)
+  }
+  // os.Exit skips deferred calls, so every exit stops the profile
+  stopProfile := func() {}
+  if *cpuProfileFlag != "" {
+    f, err := os.Create(*cpuProfileFlag)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    if err := pprof.StartCPUProfile(f); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    stopProfile = func() {
+      pprof.StopCPUProfile()
+      f.Close()
+    }
+  }
+  seed, _ := exp.ChooseSeed(flag.CommandLine)
+  runs := exp.Runs(seed)
+  sim.Parallel(len(runs), *workersFlag,
+      func(id int) sim.RunResult {
+        return exp.Execute(runs[id])
+      },
+      func(_ int, res sim.RunResult) {
+        if !*wallTimeFlag {
+          res.WallTime = 0
+        }
+        if err := out.Write(&res); err != nil {
+          fmt.Fprintln(os.Stderr, err)
+          stopProfile()
+          os.Exit(1)
+        }
+      },
+  )
+  stopProfile()
+  if err := out.Close(); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
@@ -0,0 +1,17 @@
+package sim
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/agents"
+
+// Train runs r as a training episode: the population is spawned as in
+// Execute, but the learning agents act on table and update it as they
+// go.  It returns every agent's lifetime.  Episodes sharing a table
+// must run one at a time.
+func (e *Experiment) Train(table *agents.QTable, r Run) []AgentLifetime {
+  rng := substrates.NewSplitMix64(r.Seed)
+  u := e.NewUniverse(r, rng)
+  pop := e.population(r)
+  pop.QTable, pop.Learn = table, true
+  spawned := agents.SpawnPopulation(u.Grid(), pop, rng)
+  _, lives := e.simulate(u, spawned, e.perception(r, rng), nil)
+  return lives
+}
@@ -0,0 +1,107 @@
+// Command train teaches the learning agents of an experiment by
+// running its runs over and over, one at a time, and saves the
+// agents.QTable they learnt.
+//
+//   go run ./sim/train -episodes 5000 -out q.json sim/experiments/learning.json
+//   go run ./sim/train -in q.json -out q2.json sim/experiments/learning.json
+//
+// Episode i is run i mod the number of runs, seeded with seed + i.  An
+// experiment whose agents name "qtable": "q.json", a path from the
+// experiment file's directory, then sweeps with the learnt policy,
+// frozen.  Every -report episodes the mean lifetime of
+// the learning agents over those episodes goes to stderr.
+package main
+import "flag"
+import "fmt"
+import "os"
+import "oscarkilo.com/inteluni/agents"
+import "oscarkilo.com/inteluni/sim"
+
+var seedFlag = flag.Uint64(
+    "seed", 0, "random seed, overriding the experiment's (default: clock)",)
+var episodesFlag = flag.Int(
+    "episodes", 1000, "training episodes",)
+var inFlag = flag.String(
+    "in", "", "table to continue training (default: a new one)",)
+var outFlag = flag.String(
+    "out", "", "file to save the trained table to",)
+var radiusFlag = flag.Int(
+    "radius", agents.DefaultQRadius, "window radius of a new table",)
+var alphaFlag = flag.Float64(
+    "alpha", agents.DefaultQAlpha, "learning rate of a new table",)
+var epsilonFlag = flag.Float64(
+    "epsilon", agents.DefaultQEpsilon, "exploration rate of a new table",)
+var reportFlag = flag.Int(
+    "report", 100, "episodes per progress line",)
+
+func main() {
+  flag.Usage = func() {
+    fmt.Fprintf(os.Stderr, "usage: train [flags] -out q.json experiment.json\n")
+    flag.PrintDefaults()
+  }
+  flag.Parse()
+  if flag.NArg() != 1 || *outFlag == "" {
+    flag.Usage()
+    os.Exit(2)
+  }
+  exp, err := sim.LoadExperiment(flag.Arg(0))
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+  if exp.Agents.Learning == 0 {
+    fmt.Fprintln(os.Stderr, "the experiment spawns no learning agents")
+    os.Exit(1)
+  }
+  table, err := loadTable()
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+  seed, _ := exp.ChooseSeed(flag.CommandLine)
+  runs := exp.Runs(seed)
+  var life, lives float64
+  for ep := 0; ep < *episodesFlag; ep++ {
+    r := runs[ep%len(runs)]
+    r.Seed = seed + uint64(ep)
+    for _, l := range exp.Train(table, r) {
+      if l.Kind == agents.KindLearning {
+        life += float64(l.Ticks)
+        lives++
+      }
+    }
+    if *reportFlag > 0 && (ep+1)%*reportFlag == 0 {
+      fmt.Fprintf(os.Stderr, "episode %d: mean life %.2f, %d states\n",
+          ep+1, life/lives, table.States())
+      life, lives = 0, 0
+    }
+  }
+  if err := save(table); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
+
+func loadTable() (*agents.QTable, error) {
+  if *inFlag == "" {
+    return agents.NewQTable(*radiusFlag, *alphaFlag, *epsilonFlag), nil
+  }
+  f, err := os.Open(*inFlag)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+  return agents.LoadQTable(f)
+}
+
+func save(table *agents.QTable) error {
+  f, err := os.Create(*outFlag)
+  if err != nil {
+    return err
+  }
+  if err := table.Save(f); err != nil {
+    f.Close()
+    return err
+  }
+  return f.Close()
+}
@@ -0,0 +1,71 @@
+// Command watch animates a run in the terminal: a recorded episode, or
+// with -live a run of an experiment as it is simulated.
+//
+//   go run ./sim/watch ep.json
+//   go run ./sim/watch -live -seed 7 -run 12 sim/experiments/gameofnoise.json
+//
+// R, P and M are reactive, predictive and MCTS agents, x an agent at
+// the tick it died and * several agents on one cell.  Press space to
+// pause, n and b to step, + and - to change speed and q to quit.
+package main
+import "flag"
+import "fmt"
+import "os"
+import "time"
+import "oscarkilo.com/inteluni/sim"
+import "oscarkilo.com/inteluni/view"
+
+var liveFlag = flag.Bool(
+    "live", false, "simulate the argument, an experiment, instead of "+
+    "playing a recorded episode",)
+var seedFlag = flag.Uint64(
+    "seed", 0, "with -live: base random seed, overriding the experiment's",)
+var runFlag = flag.Int(
+    "run", 0, "with -live: ID of the run to watch",)
+var delayFlag = flag.Duration(
+    "delay", 200*time.Millisecond, "time between ticks",)
+var colorFlag = flag.Bool(
+    "color", true, "draw with ANSI colors",)
+
+func main() {
+  flag.Usage = func() {
+    fmt.Fprintf(os.Stderr, "usage: watch [flags] episode.json\n"+
+        "       watch -live [flags] experiment.json\n")
+    flag.PrintDefaults()
+  }
+  flag.Parse()
+  if flag.NArg() != 1 {
+    flag.Usage()
+    os.Exit(2)
+  }
+  var frames <-chan view.Frame
+  if *liveFlag {
+    exp, err := sim.LoadExperiment(flag.Arg(0))
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    seed, _ := exp.ChooseSeed(flag.CommandLine)
+    runs := exp.Runs(seed)
+    if *runFlag < 0 || *runFlag >= len(runs) {
+      fmt.Fprintf(os.Stderr, "run %d outside 0..%d\n", *runFlag, len(runs)-1)
+      os.Exit(2)
+    }
+    frames = view.Live(exp, runs[*runFlag])
+  } else {
+    ep, err := sim.LoadEpisode(flag.Arg(0))
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    frames = view.Frames(view.EpisodeFrames(ep))
+  }
+  player := &view.Player{Out: os.Stdout, Delay: *delayFlag, Color: *colorFlag}
+  if restore, err := view.Cbreak(); err == nil {
+    defer restore()
+    player.Keys = os.Stdin
+  }
+  if err := player.Play(frames); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+  }
+}
@@ -1,4 +1,5 @@
 package substrates
+import "encoding/binary"
 import "fmt"
 
 type Pos struct {
@@ -22,30 +23,97 @@ var (
   Stay  = Move{dx:  0, dy:  0}
 )
 
+// moveNames spells the five moves in logs and episode files.
+var moveNames = map[Move]string{
+  North: "N", East: "E", South: "S", West: "W", Stay: ".",
+}
+
+func (m Move) String() string {
+  if name, ok := moveNames[m]; ok {
+    return name
+  }
+  return fmt.Sprintf("(%d,%d)", m.dx, m.dy)
+}
+
+// ParseMove is the inverse of String for the five named moves.
+func ParseMove(s string) (Move, error) {
+  for m, name := range moveNames {
+    if name == s {
+      return m, nil
+    }
+  }
+  return Stay, fmt.Errorf("unknown move %q", s)
+}
+
+func (m Move) MarshalText() ([]byte, error) {
+  if _, ok := moveNames[m]; !ok {
+    return nil, fmt.Errorf("cannot encode move %v", m)
+  }
+  return []byte(m.String()), nil
+}
+
+func (m *Move) UnmarshalText(text []byte) error {
+  var err error
+  *m, err = ParseMove(string(text))
+  return err
+}
+
+// Cell states.  Binary universes use only Empty and Live; Generations
+// rules add refractory states 2, 3, ... for cells that are dying.
+const (
+  Empty = 0
+  Live  = 1
+)
+
+// Lethal reports whether an agent on a cell in state val dies.
+// Every state but Empty is lethal: live cells and obstacles, and also
+// refractory cells, which are still occupied while they decay.
+func Lethal(val int) bool {
+  return val != Empty
+}
+
+// Grid2d is a bit-packed grid of small non-negative cell states.
+//
+// State bits are stored in planes: bit k of every cell lives in
+// planes[k], a flat slice of stride uint64 words per row, cell x of
+// row y being bit x%64 of word y*stride + x/64.  Binary grids have a
+// single plane; more are added when a larger state is stored.  Bits
+// past w in the last word of a row are always zero, so whole words can
+// be compared, counted and combined without masking.
+//
+// New grids are toroidal; see SetTopology.  Every cell of a new grid
+// is known; see Forget.
 type Grid2d struct {
-  w int
-  h int
-  v [][]int
+  w      int
+  h      int
+  stride int         // words per row
+  planes [][]uint64  // planes[0] always present
+  topo   Topology
+  unknown []uint64   // cells not known, nil if none; see Forget
 }
 
 type Evolver func(g *Grid2d, rng *SplitMix64) *Grid2d
 
+const wordBits = 64
+
 func NewGrid2d(w, h int) *Grid2d {
-  v := make([][]int, h)
-  for y := range v {
-    v[y] = make([]int, w)
-  }
+  stride := (w + wordBits - 1) / wordBits
   return &Grid2d{
-    w: w,
-    h: h,
-    v: v,
+    w:      w,
+    h:      h,
+    stride: stride,
+    planes: [][]uint64{make([]uint64, stride*h)},
   }
 }
 
+// Map sets every cell to fn of its coordinates and state, which makes
+// every cell known.
 func (g *Grid2d) Map(fn func(x, y, val int) int) {
+  g.unknown = nil
   for y := 0; y < g.h; y++ {
     for x := 0; x < g.w; x++ {
-      g.v[y][x] = fn(x, y, g.v[y][x])
+      i, bit := g.index(x, y)
+      g.set(i, bit, fn(x, y, g.get(i, bit)))
     }
   }
 }
@@ -54,7 +122,9 @@ func (g *Grid2d) SetXY(x, y, val int) {
   if !g.InBoundsXY(x, y) {
     panic("out of bounds access")
   }
-  g.v[y][x] = val
+  i, bit := g.index(x, y)
+  g.set(i, bit, val)
+  g.markKnown(i, bit)
 }
 
 func (g *Grid2d) InBoundsXY(x, y int) bool {
@@ -69,7 +139,8 @@ func (g *Grid2d) XY(x, y int) int {
   if !g.InBoundsXY(x, y) {
     panic("out of bounds access")
   }
-  return g.v[y][x]
+  i, bit := g.index(x, y)
+  return g.get(i, bit)
 }
 func (g *Grid2d) Get(p Pos) int {
   return g.XY(p.X, p.Y)
@@ -78,15 +149,187 @@ func (g *Grid2d) Get(p Pos) int {
 func (g *Grid2d) H() int { return g.h }
 func (g *Grid2d) W() int { return g.w }
 
+// Binary reports whether every cell is Empty or Live.
+func (g *Grid2d) Binary() bool {
+  for _, plane := range g.planes[1:] {
+    for _, word := range plane {
+      if word != 0 {
+        return false
+      }
+    }
+  }
+  return true
+}
+
 // Deep copy of the grid.
 func (g *Grid2d) Clone() *Grid2d {
-  dup := NewGrid2d(g.w, g.h)
-  for y := 0; y < g.h; y++ {
-    copy(dup.v[y], g.v[y])
+  dup := &Grid2d{
+    w:      g.w,
+    h:      g.h,
+    stride: g.stride,
+    planes: make([][]uint64, len(g.planes)),
+    topo:   g.topo,
+  }
+  for k, plane := range g.planes {
+    dup.planes[k] = make([]uint64, len(plane))
+    copy(dup.planes[k], plane)
+  }
+  if g.unknown != nil {
+    dup.unknown = append([]uint64(nil), g.unknown...)
   }
   return dup
 }
 
+// Equal reports whether both grids have the same shape and cells.
+func (g *Grid2d) Equal(o *Grid2d) bool {
+  if g.w != o.w || g.h != o.h {
+    return false
+  }
+  n := len(g.planes)
+  if len(o.planes) > n {
+    n = len(o.planes)
+  }
+  for i := 0; i < g.stride*g.h; i++ {
+    for k := 0; k < n; k++ {
+      if g.word(k, i) != o.word(k, i) {
+        return false
+      }
+    }
+  }
+  return true
+}
+
+// MarshalBinary encodes the grid: its width, height, topology and
+// number of planes as uvarints, then every plane's words, little-endian.
+func (g *Grid2d) MarshalBinary() ([]byte, error) {
+  var buf []byte
+  for _, n := range []int{g.w, g.h, int(g.topo), len(g.planes)} {
+    buf = binary.AppendUvarint(buf, uint64(n))
+  }
+  for _, plane := range g.planes {
+    for _, word := range plane {
+      buf = binary.LittleEndian.AppendUint64(buf, word)
+    }
+  }
+  return buf, nil
+}
+
+// UnmarshalBinary decodes what MarshalBinary wrote into g.
+func (g *Grid2d) UnmarshalBinary(data []byte) error {
+  var head [4]int
+  for i := range head {
+    n, size := binary.Uvarint(data)
+    if size <= 0 || n > 1<<31 {
+      return fmt.Errorf("grid: bad header")
+    }
+    head[i] = int(n)
+    data = data[size:]
+  }
+  w, h, topo, planes := head[0], head[1], head[2], head[3]
+  if w == 0 || h == 0 || topo >= len(topologyNames) ||
+      planes < 1 || planes > 64 {
+    return fmt.Errorf("grid: bad header")
+  }
+  // check the size before allocating, so that a corrupt header cannot
+  // ask for more memory than data holds; with w, h ≤ 2³¹ words is at
+  // most 2⁵⁶, and bounding it by len(data) keeps the product in range
+  words := (w + wordBits - 1) / wordBits * h
+  if words > len(data)/(8*planes) || len(data) != planes*words*8 {
+    return fmt.Errorf("grid: %d bytes of cells for %d planes of %dx%d",
+        len(data), planes, w, h)
+  }
+  dec := NewGrid2d(w, h)
+  dec.topo = Topology(topo)
+  dec.planes = make([][]uint64, planes)
+  mask := dec.lastWordMask()
+  for k := range dec.planes {
+    dec.planes[k] = make([]uint64, words)
+    for i := range dec.planes[k] {
+      word := binary.LittleEndian.Uint64(data)
+      data = data[8:]
+      if (i+1)%dec.stride == 0 && word&^mask != 0 {
+        return fmt.Errorf("grid: bits set past the last column")
+      }
+      dec.planes[k][i] = word
+    }
+  }
+  *g = *dec
+  return nil
+}
+
+// Live returns a binary grid marking the cells in state Live.
+// A binary grid is returned as is, not copied.
+func (g *Grid2d) Live() *Grid2d {
+  if len(g.planes) == 1 {
+    return g
+  }
+  live := NewGrid2d(g.w, g.h)
+  live.topo = g.topo
+  for i := range live.planes[0] {
+    live.planes[0][i] = g.planes[0][i] &^ g.higherWord(i)
+  }
+  return live
+}
+
+// row returns the plane 0 words of row y, aliasing the grid storage.
+func (g *Grid2d) row(y int) []uint64 {
+  return g.planes[0][y*g.stride : (y+1)*g.stride]
+}
+
+// word returns word i of plane k, zero for planes not allocated.
+func (g *Grid2d) word(k, i int) uint64 {
+  if k >= len(g.planes) {
+    return 0
+  }
+  return g.planes[k][i]
+}
+
+// higherWord ORs word i of every plane above 0.
+func (g *Grid2d) higherWord(i int) uint64 {
+  var w uint64
+  for _, plane := range g.planes[1:] {
+    w |= plane[i]
+  }
+  return w
+}
+
+// occupiedWord marks the non-Empty cells of word i.
+func (g *Grid2d) occupiedWord(i int) uint64 {
+  return g.planes[0][i] | g.higherWord(i)
+}
+
+func (g *Grid2d) index(x, y int) (int, uint) {
+  return y*g.stride + x/wordBits, uint(x % wordBits)
+}
+
+func (g *Grid2d) get(i int, bit uint) int {
+  val := 0
+  for k, plane := range g.planes {
+    val |= int(plane[i] >> bit & 1) << uint(k)
+  }
+  return val
+}
+
+func (g *Grid2d) set(i int, bit uint, val int) {
+  if val < 0 {
+    panic(fmt.Sprintf("cell state %d is negative", val))
+  }
+  for val >> uint(len(g.planes)) != 0 {
+    g.planes = append(g.planes, make([]uint64, len(g.planes[0])))
+  }
+  for k, plane := range g.planes {
+    plane[i] = plane[i] &^ (1 << bit) | uint64(val >> uint(k) & 1) << bit
+  }
+}
+
+// lastWordMask keeps the bits of the final word of a row that hold cells.
+func (g *Grid2d) lastWordMask() uint64 {
+  if r := g.w % wordBits; r != 0 {
+    return 1<<uint(r) - 1
+  }
+  return ^uint64(0)
+}
+
 func (g *Grid2d) OntoStdout() {
   for y := 0; y < g.H(); y++ {
     for x := 0; x < g.W(); x++ {
@@ -97,8 +340,7 @@ func (g *Grid2d) OntoStdout() {
         case 1:
           fmt.Print("#")
         default:
-          emsg := fmt.Sprintf("unexpected %d at (%d, %d)", val, x, y)
-          panic(emsg)
+          fmt.Print("+") // refractory
       }
     }
     fmt.Println()
@@ -116,15 +358,14 @@ func (g *Grid2d) OntoStdoutAgent(ax, ay int) {
           } else {
             fmt.Print("_")
           }
-        case 1:
+        default:
           if x == ax && y == ay {
             fmt.Print("a")
-          } else {
+          } else if val == 1 {
             fmt.Print("#")
+          } else {
+            fmt.Print("+") // refractory
           }
-        default:
-          emsg := fmt.Sprintf("unexpected %d at (%d, %d)", val, x, y)
-          panic(emsg)
       }
     }
     fmt.Println()
@@ -0,0 +1,299 @@
+package substrates
+import "encoding/binary"
+import "testing"
+
+func randomGrid(w, h int, rng *SplitMix64) *Grid2d {
+  g := NewGrid2d(w, h)
+  g.Map(func(_, _, _ int) int {
+    return rng.Intn(2)
+  })
+  return g
+}
+
+func TestGrid2dPackedAcrossWords(t *testing.T) {
+  g := NewGrid2d(130, 3)
+  for _, x := range []int{0, 63, 64, 127, 128, 129} {
+    g.SetXY(x, 1, 1)
+  }
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      want := 0
+      if y == 1 && (x == 0 || x == 63 || x == 64 ||
+          x == 127 || x == 128 || x == 129) {
+        want = 1
+      }
+      if got := g.XY(x, y); got != want {
+        t.Fatalf("cell (%d,%d): expected %d, got %d", x, y, want, got)
+      }
+    }
+  }
+  g.SetXY(64, 1, 0)
+  if g.XY(64, 1) != 0 || g.XY(63, 1) != 1 {
+    t.Fatalf("clearing one bit disturbed its neighbor")
+  }
+}
+
+func TestGrid2dCloneIndependent(t *testing.T) {
+  g := randomGrid(70, 5, NewSplitMix64(3))
+  dup := g.Clone()
+  if !dup.Equal(g) {
+    t.Fatalf("clone differs from original")
+  }
+  dup.SetXY(69, 4, 1-dup.XY(69, 4))
+  if dup.Equal(g) {
+    t.Fatalf("clone shares storage with original")
+  }
+}
+
+func TestGrid2dMultiState(t *testing.T) {
+  g := NewGrid2d(70, 2)
+  g.SetXY(65, 1, 5)
+  g.SetXY(3, 0, 1)
+  if g.XY(65, 1) != 5 || g.XY(3, 0) != 1 || g.XY(64, 1) != 0 {
+    t.Fatalf("multi-state cells not stored faithfully")
+  }
+  if g.Binary() {
+    t.Fatalf("grid holding 5 reported as binary")
+  }
+  dup := g.Clone()
+  g.SetXY(65, 1, 0)
+  if dup.XY(65, 1) != 5 {
+    t.Fatalf("clone shares higher planes with original")
+  }
+  if !g.Binary() {
+    t.Fatalf("grid of 0s and 1s reported as non-binary")
+  }
+  live := dup.Live()
+  if live.XY(65, 1) != 0 || live.XY(3, 0) != 1 {
+    t.Fatalf("Live() should keep only state 1")
+  }
+}
+
+func TestGrid2dRejectsNegative(t *testing.T) {
+  defer func() {
+    if r := recover(); r == nil {
+      t.Fatalf("expected panic storing a negative state")
+    }
+  }()
+  NewGrid2d(2, 2).SetXY(0, 0, -1)
+}
+
+func TestLethal(t *testing.T) {
+  if Lethal(Empty) {
+    t.Errorf("empty cells must be safe")
+  }
+  for _, v := range []int{Live, 2, 7} {
+    if !Lethal(v) {
+      t.Errorf("state %d must be lethal", v)
+    }
+  }
+}
+
+// naiveLifeStep is the cell-by-cell reference for LifeStep.
+func naiveLifeStep(g *Grid2d, birth, survive uint16) *Grid2d {
+  w, h := g.W(), g.H()
+  next := NewGrid2d(w, h)
+  next.SetTopology(g.Topology())
+  for y := 0; y < h; y++ {
+    for x := 0; x < w; x++ {
+      n := 0
+      for dy := -1; dy <= 1; dy++ {
+        for dx := -1; dx <= 1; dx++ {
+          if dx == 0 && dy == 0 {
+            continue
+          }
+          if nx, ny, ok := g.Resolve(x+dx, y+dy); ok {
+            n += g.XY(nx, ny)
+          }
+        }
+      }
+      rule := birth
+      if g.XY(x, y) == 1 {
+        rule = survive
+      }
+      next.SetXY(x, y, int(rule>>uint(n)&1))
+    }
+  }
+  return next
+}
+
+func TestLifeStepMatchesNaive(t *testing.T) {
+  rng := NewSplitMix64(11)
+  rules := []struct{ birth, survive uint16 }{
+    {1<<3, 1<<2 | 1<<3},                          // B3/S23
+    {1<<3 | 1<<6, 1<<2 | 1<<3},                   // B36/S23
+    {1<<2, 0},                                    // B2/S
+    {1<<0 | 1<<1, 1<<8},                          // B01/S8
+  }
+  for _, w := range []int{1, 2, 3, 63, 64, 65, 130} {
+    for _, h := range []int{1, 3, 7} {
+      for _, r := range rules {
+        for topo := Torus; topo <= TwistedTorus; topo++ {
+          g := randomGrid(w, h, rng)
+          g.SetTopology(topo)
+          got := LifeStep(g, r.birth, r.survive)
+          want := naiveLifeStep(g, r.birth, r.survive)
+          if !got.Equal(want) {
+            t.Fatalf("%dx%d %v B%b/S%b: word-level step differs from naive",
+                w, h, topo, r.birth, r.survive)
+          }
+          if got.Topology() != topo {
+            t.Fatalf("successor lost topology %v", topo)
+          }
+        }
+      }
+    }
+  }
+}
+
+func TestGenerationsStepBriansBrain(t *testing.T) {
+  // B2/S/C3: live cells always decay, refractory cells then vanish.
+  g := NewGrid2d(6, 6)
+  g.SetXY(2, 2, Live)
+  g.SetXY(3, 2, Live)
+  g.SetXY(2, 3, 2)
+  next := GenerationsStep(g, 1<<2, 0, 3)
+  if next.XY(2, 2) != 2 || next.XY(3, 2) != 2 {
+    t.Fatalf("live cells should enter refractory state 2")
+  }
+  if next.XY(2, 3) != Empty {
+    t.Fatalf("last refractory state should return to empty")
+  }
+  if next.XY(2, 1) != Live || next.XY(3, 1) != Live {
+    t.Fatalf("empty cells with two live neighbors should be born")
+  }
+  if next.XY(3, 3) != Live {
+    t.Fatalf("refractory neighbors must not count as live")
+  }
+}
+
+func TestGenerationsStepTwoStatesIsLife(t *testing.T) {
+  rng := NewSplitMix64(4)
+  for _, w := range []int{5, 64, 70} {
+    g := randomGrid(w, 9, rng)
+    got := GenerationsStep(g, 1<<3, 1<<2 | 1<<3, 2)
+    if !got.Equal(LifeStep(g, 1<<3, 1<<2 | 1<<3)) {
+      t.Fatalf("width %d: C2 Generations differs from Life", w)
+    }
+  }
+}
+
+func TestMoveText(t *testing.T) {
+  for _, m := range []Move{North, East, South, West, Stay} {
+    text, err := m.MarshalText()
+    if err != nil {
+      t.Fatalf("%v: %v", m, err)
+    }
+    var back Move
+    if err := back.UnmarshalText(text); err != nil || back != m {
+      t.Errorf("%q parsed as %v, %v", text, back, err)
+    }
+  }
+  if _, err := ParseMove("NE"); err == nil {
+    t.Errorf("ParseMove accepted NE")
+  }
+  if _, err := (Move{dx: 2}).MarshalText(); err == nil {
+    t.Errorf("marshalled a two-cell move")
+  }
+}
+
+func TestGrid2dBinary(t *testing.T) {
+  rng := NewSplitMix64(8)
+  g := randomGrid(70, 5, rng)
+  g.SetXY(69, 4, 5)  // three planes
+  g.SetTopology(KleinBottle)
+  data, err := g.MarshalBinary()
+  if err != nil {
+    t.Fatal(err)
+  }
+  var back Grid2d
+  if err := back.UnmarshalBinary(data); err != nil {
+    t.Fatal(err)
+  }
+  if !back.Equal(g) || back.Topology() != KleinBottle || back.XY(69, 4) != 5 {
+    t.Errorf("grid changed in a round trip")
+  }
+  for _, bad := range [][]byte{nil, data[:len(data)-1], append(data, 0)} {
+    if err := back.UnmarshalBinary(bad); err == nil {
+      t.Errorf("decoded %d bytes of %d", len(bad), len(data))
+    }
+  }
+  // headers that would allocate without bound, or nothing
+  huge := func(w, h, planes uint64) []byte {
+    var b []byte
+    for _, n := range []uint64{w, h, 0, planes} {
+      b = binary.AppendUvarint(b, n)
+    }
+    return b
+  }
+  for _, bad := range [][]byte{
+    huge(1<<31, 1<<31, 64), huge(1<<31, 1<<31, 1), huge(64, 1<<31, 2),
+    huge(0, 5, 1), huge(5, 0, 1),
+  } {
+    if err := back.UnmarshalBinary(bad); err == nil {
+      t.Errorf("decoded header %v", bad)
+    }
+  }
+  // a bit past column 70 breaks the padding invariant
+  data[len(data)-1] |= 0x80
+  if err := back.UnmarshalBinary(data); err == nil {
+    t.Errorf("decoded a grid with padding bits set")
+  }
+}
+
+func TestGridUnknown(t *testing.T) {
+  g := NewGrid2d(70, 3)
+  g.SetXY(65, 1, Live)
+  if g.Partial() || !g.KnownXY(65, 1) {
+    t.Fatalf("a new grid is not fully known")
+  }
+  g.Forget(65, 1)
+  g.Forget(0, 2)
+  if !g.Partial() || g.KnownXY(65, 1) || g.XY(65, 1) != Empty {
+    t.Errorf("forgotten cell is known or not Empty")
+  }
+  if !g.KnownXY(64, 1) || !g.KnownXY(65, 0) {
+    t.Errorf("Forget marked other cells unknown")
+  }
+  dup := g.Clone()
+  g.SetXY(65, 1, 2)
+  if !g.KnownXY(65, 1) || dup.KnownXY(65, 1) {
+    t.Errorf("SetXY did not mark the cell known, or Clone shares marks")
+  }
+  g.SetXY(0, 2, Empty)
+  if g.Partial() {
+    t.Errorf("grid still partial once every cell is set")
+  }
+  dup.Map(func(x, y, val int) int { return val })
+  if dup.Partial() {
+    t.Errorf("Map left cells unknown")
+  }
+}
+
+func TestGridHash(t *testing.T) {
+  g := randomGrid(70, 5, NewSplitMix64(6))
+  h := g.Hash()
+  if g.Clone().Hash() != h {
+    t.Fatalf("a clone hashes differently")
+  }
+  c := g.Clone()
+  c.SetXY(69, 4, 1 - c.XY(69, 4))
+  if c.Hash() == h {
+    t.Fatalf("changing a cell kept the hash")
+  }
+  c.SetXY(69, 4, g.XY(69, 4))
+  if c.Hash() != h {
+    t.Fatalf("restoring the cell did not restore the hash")
+  }
+  c.Forget(3, 3)
+  if c.Hash() != h {
+    t.Fatalf("unknown marks changed the hash")
+  }
+  c.SetTopology(Bounded)
+  if c.Hash() == h {
+    t.Fatalf("changing the topology kept the hash")
+  }
+  if NewGrid2d(4, 6).Hash() == NewGrid2d(6, 4).Hash() {
+    t.Fatalf("empty grids of different shapes hash alike")
+  }
+}
@@ -0,0 +1,49 @@
+package substrates
+import "math/bits"
+
+// Hash returns a Zobrist hash of the grid (Zobrist, 1970): the XOR of
+// a fixed pseudo-random key for every set bit of every state plane,
+// that is for every cell and state bit, started from a key for the
+// grid's shape and topology.  Grids that are Equal, with the same
+// topology, hash alike; any other two collide with probability about
+// 2⁻⁶⁴.  Unknown marks are not hashed.
+//
+// Keys are computed, not tabulated, by Mix64 of the bit's position, so
+// hashing costs one mix per non-Empty cell bit, and a grid may be
+// rehashed incrementally by XORing the keys of the bits that changed.
+//
+// References
+// ----------
+// • Zobrist, A. L. “A New Hashing Method with Application for Game
+//   Playing.” Tech. Rep. 88, Univ. of Wisconsin, 1970.
+func (g *Grid2d) Hash() uint64 {
+  h := Mix64(uint64(g.w)<<40 ^ uint64(g.h)<<16 ^ uint64(g.topo))
+  for k, plane := range g.planes {
+    for i, word := range plane {
+      for w := word; w != 0; w &= w - 1 {
+        h ^= zobristKey(k, i*wordBits + bits.TrailingZeros64(w))
+      }
+    }
+  }
+  return h
+}
+
+// zobristKey is the key of bit k of the cell at bit index i of the
+// planes, i = y*stride*64 + x.
+func zobristKey(k, i int) uint64 {
+  return Mix64(uint64(k)<<48 ^ uint64(i) ^ 0x5A0B215A0B215A0B)
+}
+
+// Mix64 is the output function of SplitMix64 (Steele et al., 2014):
+// a bijection on 64-bit words that scrambles every input bit into every
+// output bit, for hashing.
+//
+// References
+// ----------
+// • Steele, G. L., Lea, D. & Flood, C. H. “Fast Splittable
+//   Pseudorandom Number Generators.” *Proc. OOPSLA 2014*.
+func Mix64(z uint64) uint64 {
+  z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+  z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+  return z ^ (z >> 31)
+}
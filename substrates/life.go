@@ -0,0 +1,222 @@
+package substrates
+import "math/bits"
+
+// Word-level stepping of outer-totalistic ("Life-like") rules.
+//
+// A rule is a pair of bit masks over the live-neighbor count n = 0..8:
+// bit n of birth is set when a dead cell with n live neighbors comes
+// alive, bit n of survive when a live cell with n live neighbors stays
+// alive.  Conway's B3/S23 is birth = 1<<3, survive = 1<<2 | 1<<3.
+//
+// Counts are computed 64 cells at a time.  The eight neighbor words of
+// each grid word are summed with a bit-sliced adder, so the count of
+// every cell is spread over four words c0..c3 holding its binary digits.
+
+// LifeStep returns the successor of g under the rule birth/survive.
+// Only cells in state Live count as alive; the result is binary.
+// Neighbors follow the topology of g, which the result inherits.
+// g is left unchanged.
+func LifeStep(g *Grid2d, birth, survive uint16) *Grid2d {
+  live := g.Live()
+  next := NewGrid2d(g.w, g.h)
+  next.topo = g.topo
+  out := next.planes[0]
+  live.sweepNeighbors(func(i int, cur uint64, c *neighborCount) {
+    out[i] = c.apply(cur, birth, survive)
+  })
+  next.maskRows()
+  return next
+}
+
+// GenerationsStep returns the successor of g under a Generations rule
+// with the given number of states.  Empty cells are born into Live as
+// under birth; Live cells stay Live as under survive and otherwise start
+// to decay.  A decaying cell steps through the refractory states 2, 3,
+// ..., states-1 and then back to Empty, ignoring its neighbors.  Only
+// Live cells count as neighbors.  With states == 2 this is LifeStep.
+func GenerationsStep(g *Grid2d, birth, survive uint16, states int) *Grid2d {
+  if states < 2 {
+    panic("Generations rules need at least 2 states")
+  }
+  live := g.Live()
+  next := NewGrid2d(g.w, g.h)
+  next.topo = g.topo
+  live.sweepNeighbors(func(i int, cur uint64, c *neighborCount) {
+    occupied := g.occupiedWord(i)
+    born := c.apply(cur, birth, 0) &^ occupied
+    kept := c.apply(cur, 0, survive)
+    next.planes[0][i] = born | kept
+    y, x0 := i/g.stride, i%g.stride*wordBits
+    for d := occupied &^ kept; d != 0; d &= d - 1 {
+      x := x0 + bits.TrailingZeros64(d)
+      if s := g.XY(x, y) + 1; s < states {
+        next.SetXY(x, y, s)
+      }
+    }
+  })
+  next.maskRows()
+  return next
+}
+
+// sweepNeighbors counts the Live neighbors of every word of a binary
+// grid and calls fn with the word index, the word itself and its counts.
+func (g *Grid2d) sweepNeighbors(fn func(i int, cur uint64, c *neighborCount)) {
+  edge := g.topo.edgeFill()
+  above := make([]uint64, g.stride)
+  below := make([]uint64, g.stride)
+  for y := 0; y < g.h; y++ {
+    up := g.neighborRow(y-1, above)
+    mid := g.row(y)
+    down := g.neighborRow(y+1, below)
+    for i := range mid {
+      var c neighborCount
+      c.add(westWord(up, i, g.w, edge))
+      c.add(up[i])
+      c.add(eastWord(up, i, g.w, edge))
+      c.add(westWord(mid, i, g.w, edge))
+      c.add(eastWord(mid, i, g.w, edge))
+      c.add(westWord(down, i, g.w, edge))
+      c.add(down[i])
+      c.add(eastWord(down, i, g.w, edge))
+      fn(y*g.stride+i, mid[i], &c)
+    }
+  }
+}
+
+// neighborRow returns the plane 0 words seen as row y, which may lie
+// past the top or bottom edge.  Rows inside the grid, and wrapped rows
+// of a torus, alias the grid; other edge rows are built in buf.
+func (g *Grid2d) neighborRow(y int, buf []uint64) []uint64 {
+  if y >= 0 && y < g.h {
+    return g.row(y)
+  }
+  switch g.topo {
+    case Torus:
+      return g.row(floorMod(y, g.h))
+    case Bounded:
+      for i := range buf {
+        buf[i] = 0
+      }
+      return buf
+  }
+  for i := range buf {
+    buf[i] = 0
+  }
+  for x := 0; x < g.w; x++ {
+    rx, ry, _ := g.Resolve(x, y)
+    i, bit := g.index(rx, ry)
+    buf[x/wordBits] |= (g.planes[0][i] >> bit & 1) << uint(x%wordBits)
+  }
+  return buf
+}
+
+// maskRows clears plane 0 bits past the last cell of each row.
+func (g *Grid2d) maskRows() {
+  if g.stride == 0 {
+    return
+  }
+  mask := g.lastWordMask()
+  for y := 0; y < g.h; y++ {
+    g.planes[0][(y+1)*g.stride-1] &= mask
+  }
+}
+
+// edgeFill says what lies past the left and right edges of a row.
+type edgeFill int
+
+const (
+  edgeWrap   edgeFill = iota // the opposite edge cell
+  edgeEmpty                  // nothing (Bounded)
+  edgeMirror                 // the edge cell itself (Reflective)
+)
+
+func (t Topology) edgeFill() edgeFill {
+  switch t {
+    case Bounded:
+      return edgeEmpty
+    case Reflective:
+      return edgeMirror
+    default:
+      return edgeWrap
+  }
+}
+
+// westWord returns word i of row r shifted so that bit x holds the
+// cell at x-1, filling x = 0 as edge says.
+func westWord(r []uint64, i, w int, edge edgeFill) uint64 {
+  v := r[i] << 1
+  if i > 0 {
+    return v | r[i-1] >> (wordBits - 1)
+  }
+  switch edge {
+    case edgeWrap:
+      v |= r[len(r)-1] >> uint((w-1)%wordBits) & 1
+    case edgeMirror:
+      v |= r[0] & 1
+  }
+  return v
+}
+
+// eastWord returns word i of row r shifted so that bit x holds the
+// cell at x+1, filling x = w-1 as edge says.
+func eastWord(r []uint64, i, w int, edge edgeFill) uint64 {
+  v := r[i] >> 1
+  if i+1 < len(r) {
+    return v | r[i+1] << (wordBits - 1)
+  }
+  last := uint((w - 1) % wordBits)
+  switch edge {
+    case edgeWrap:
+      v |= (r[0] & 1) << last
+    case edgeMirror:
+      v |= r[i] & (1 << last)
+  }
+  return v
+}
+
+// neighborCount holds 64 counts in bit-sliced form, c[k] being the
+// words of binary digit k.  Four digits suffice for counts up to 8.
+type neighborCount struct {
+  c [4]uint64
+}
+
+// add increments the count of every cell whose bit is set in word.
+func (n *neighborCount) add(word uint64) {
+  carry := word
+  for k := 0; k < len(n.c) && carry != 0; k++ {
+    n.c[k], carry = n.c[k]^carry, n.c[k]&carry
+  }
+}
+
+// is returns the cells whose count equals v.
+func (n *neighborCount) is(v int) uint64 {
+  m := ^uint64(0)
+  for k := range n.c {
+    if v>>uint(k)&1 == 1 {
+      m &= n.c[k]
+    } else {
+      m &^= n.c[k]
+    }
+  }
+  return m
+}
+
+// apply computes the next word for live cells cur under birth/survive.
+func (n *neighborCount) apply(cur uint64, birth, survive uint16) uint64 {
+  var next uint64
+  for v := 0; v <= 8; v++ {
+    born := birth>>uint(v)&1 == 1
+    kept := survive>>uint(v)&1 == 1
+    if !born && !kept {
+      continue
+    }
+    m := n.is(v)
+    if born {
+      next |= m &^ cur
+    }
+    if kept {
+      next |= m & cur
+    }
+  }
+  return next
+}
@@ -1,4 +1,7 @@
 package substrates
+import "encoding/binary"
+import "fmt"
+import "strconv"
 
 // SplitMix64: fast, simple, cloneable RNG
 type SplitMix64 struct {
@@ -12,10 +15,7 @@ func NewSplitMix64(seed uint64) *SplitMix64 {
 // Returns next uint64
 func (r *SplitMix64) NextUint64() uint64 {
   r.state += 0x9E3779B97F4A7C15
-  z := r.state
-  z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
-  z = (z ^ (z >> 27)) * 0x94D049BB133111EB
-  return z ^ (z >> 31)
+  return Mix64(r.state)
 }
 
 // Returns float64 in [0.0, 1.0)
@@ -48,3 +48,32 @@ func (r *SplitMix64) NewFromSelf() *SplitMix64 {
 func (r *SplitMix64) Clone() *SplitMix64 {
   return &SplitMix64{state: r.state}
 }
+
+// MarshalBinary encodes the generator's state in 8 bytes, so that a
+// paused run resumes with the same stream.
+func (r *SplitMix64) MarshalBinary() ([]byte, error) {
+  return binary.BigEndian.AppendUint64(nil, r.state), nil
+}
+
+func (r *SplitMix64) UnmarshalBinary(data []byte) error {
+  if len(data) != 8 {
+    return fmt.Errorf("SplitMix64: want 8 bytes of state, got %d", len(data))
+  }
+  r.state = binary.BigEndian.Uint64(data)
+  return nil
+}
+
+// MarshalText writes the state as a decimal number, a string in JSON,
+// where a plain number would lose precision to many readers.
+func (r *SplitMix64) MarshalText() ([]byte, error) {
+  return strconv.AppendUint(nil, r.state, 10), nil
+}
+
+func (r *SplitMix64) UnmarshalText(text []byte) error {
+  state, err := strconv.ParseUint(string(text), 10, 64)
+  if err != nil {
+    return fmt.Errorf("SplitMix64: bad state %q", text)
+  }
+  r.state = state
+  return nil
+}
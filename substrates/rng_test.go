@@ -33,3 +33,31 @@ func TestSplitMix64Clone(t *testing.T) {
     }
   }
 }
+
+func TestSplitMix64Marshal(t *testing.T) {
+  rng := NewSplitMix64(1<<63 + 12345)
+  rng.NextUint64()
+  bin, err := rng.MarshalBinary()
+  if err != nil {
+    t.Fatal(err)
+  }
+  text, err := rng.MarshalText()
+  if err != nil {
+    t.Fatal(err)
+  }
+  var fromBin, fromText SplitMix64
+  if err := fromBin.UnmarshalBinary(bin); err != nil {
+    t.Fatal(err)
+  }
+  if err := fromText.UnmarshalText(text); err != nil {
+    t.Fatal(err)
+  }
+  want := rng.NextUint64()
+  if fromBin.NextUint64() != want || fromText.NextUint64() != want {
+    t.Errorf("restored generators diverge")
+  }
+  if fromBin.UnmarshalBinary(bin[1:]) == nil ||
+      fromText.UnmarshalText([]byte("-1")) == nil {
+    t.Errorf("accepted a bad state")
+  }
+}
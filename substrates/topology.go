@@ -0,0 +1,113 @@
+package substrates
+import "fmt"
+
+// Topology says how a grid's edges connect.  It governs neighbor
+// lookups in the universes, agent moves, and the predictive lookahead,
+// all of which go through Resolve or Step.
+type Topology int
+
+const (
+  Torus        Topology = iota // both axes wrap around (the default)
+  Bounded                      // dead border: nothing beyond the edges
+  Reflective                   // edges mirror: the cell past an edge is the edge cell
+  KleinBottle                  // x wraps; crossing the y edge mirrors x
+  TwistedTorus                 // x wraps; crossing the y edge shifts x by W/2
+)
+
+var topologyNames = []string{
+  Torus:        "torus",
+  Bounded:      "bounded",
+  Reflective:   "reflective",
+  KleinBottle:  "klein",
+  TwistedTorus: "twisted",
+}
+
+func (t Topology) String() string {
+  if t < 0 || int(t) >= len(topologyNames) {
+    return fmt.Sprintf("Topology(%d)", int(t))
+  }
+  return topologyNames[t]
+}
+
+// ParseTopology accepts the names produced by Topology.String.
+func ParseTopology(s string) (Topology, error) {
+  for t, name := range topologyNames {
+    if s == name {
+      return Topology(t), nil
+    }
+  }
+  return Torus, fmt.Errorf("unknown topology %q", s)
+}
+
+func (g *Grid2d) Topology() Topology {
+  return g.topo
+}
+
+// SetTopology changes how the edges of g connect.  Clones and the
+// successors computed by LifeStep and GenerationsStep inherit it.
+func (g *Grid2d) SetTopology(t Topology) {
+  if t < 0 || int(t) >= len(topologyNames) {
+    panic("unknown topology")
+  }
+  g.topo = t
+}
+
+// Resolve maps a possibly off-grid coordinate to the grid cell it
+// denotes under the grid's topology.  It returns false when the
+// coordinate lies beyond a Bounded edge and so denotes no cell.
+func (g *Grid2d) Resolve(x, y int) (int, int, bool) {
+  if g.InBoundsXY(x, y) {
+    return x, y, true
+  }
+  switch g.topo {
+    case Bounded:
+      return x, y, false
+    case Reflective:
+      return reflect(x, g.w), reflect(y, g.h), true
+    case KleinBottle:
+      laps := floorDiv(y, g.h)
+      x = floorMod(x, g.w)
+      if laps%2 != 0 {
+        x = g.w - 1 - x
+      }
+      return x, floorMod(y, g.h), true
+    case TwistedTorus:
+      laps := floorDiv(y, g.h)
+      return floorMod(x+laps*(g.w/2), g.w), floorMod(y, g.h), true
+    default:
+      return floorMod(x, g.w), floorMod(y, g.h), true
+  }
+}
+
+// Step returns where an agent at p ends up after move m.  A move that
+// would leave a Bounded or Reflective grid leaves the agent in place.
+func (g *Grid2d) Step(p Pos, m Move) Pos {
+  x, y, ok := g.Resolve(p.X+m.DX(), p.Y+m.DY())
+  if !ok {
+    return p
+  }
+  return Pos{X: x, Y: y}
+}
+
+// reflect folds v into [0, n) mirroring at both edges, so -1 maps to 0
+// and n maps to n-1.
+func reflect(v, n int) int {
+  period := 2 * n
+  v = floorMod(v, period)
+  if v >= n {
+    v = period - 1 - v
+  }
+  return v
+}
+
+func floorDiv(a, n int) int {
+  q := a / n
+  if a%n != 0 && a < 0 {
+    q--
+  }
+  return q
+}
+
+func floorMod(a, n int) int {
+  return a - floorDiv(a, n)*n
+}
@@ -0,0 +1,70 @@
+package substrates
+import "testing"
+
+func TestResolve(t *testing.T) {
+  cases := []struct {
+    topo   Topology
+    x, y   int
+    wx, wy int
+    ok     bool
+  }{
+    {Torus, -1, 0, 4, 0, true},
+    {Torus, 5, 4, 0, 0, true},
+    {Bounded, -1, 0, 0, 0, false},
+    {Bounded, 2, 2, 2, 2, true},
+    {Reflective, -1, 4, 0, 3, true},
+    {Reflective, 5, -2, 4, 1, true},
+    {KleinBottle, 1, -1, 3, 3, true},
+    {KleinBottle, -1, 4, 0, 0, true},
+    {KleinBottle, -1, 2, 4, 2, true},
+    {TwistedTorus, 0, 4, 2, 0, true},
+    {TwistedTorus, 2, -1, 0, 3, true},
+  }
+  for _, c := range cases {
+    g := NewGrid2d(5, 4)
+    g.SetTopology(c.topo)
+    x, y, ok := g.Resolve(c.x, c.y)
+    if ok != c.ok || (ok && (x != c.wx || y != c.wy)) {
+      t.Errorf("%v Resolve(%d,%d) = (%d,%d,%v), want (%d,%d,%v)",
+          c.topo, c.x, c.y, x, y, ok, c.wx, c.wy, c.ok)
+    }
+  }
+}
+
+func TestStepAtEdges(t *testing.T) {
+  corner := Pos{X: 0, Y: 0}
+  want := map[Topology]Pos{
+    Torus:        {X: 0, Y: 3},
+    Bounded:      {X: 0, Y: 0},
+    Reflective:   {X: 0, Y: 0},
+    KleinBottle:  {X: 4, Y: 3},
+    TwistedTorus: {X: 3, Y: 3},
+  }
+  for topo, w := range want {
+    g := NewGrid2d(5, 4)
+    g.SetTopology(topo)
+    if got := g.Step(corner, North); got != w {
+      t.Errorf("%v: North from %v went to %v, want %v", topo, corner, got, w)
+    }
+  }
+}
+
+func TestParseTopology(t *testing.T) {
+  for topo := Torus; topo <= TwistedTorus; topo++ {
+    got, err := ParseTopology(topo.String())
+    if err != nil || got != topo {
+      t.Errorf("round trip of %v gave %v, %v", topo, got, err)
+    }
+  }
+  if _, err := ParseTopology("sphere"); err == nil {
+    t.Errorf("expected error for unknown topology")
+  }
+}
+
+func TestCloneKeepsTopology(t *testing.T) {
+  g := NewGrid2d(3, 3)
+  g.SetTopology(KleinBottle)
+  if g.Clone().Topology() != KleinBottle {
+    t.Fatalf("clone lost topology")
+  }
+}
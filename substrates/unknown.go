@@ -0,0 +1,57 @@
+package substrates
+
+// ---------- Unknown cells ----------
+// A grid can also stand for what an observer knows of a universe:
+// cells it cannot see are marked unknown and read Empty.  The marks are
+// kept in a bit plane of their own, absent while every cell is known,
+// so fully known grids cost nothing extra.  Setting a cell makes it
+// known, and grids computed from a partial grid (LifeStep and the
+// evolvers) read its unknown cells as Empty and are fully known, so an
+// observer that wants to look ahead fills the unknown cells in first.
+// Equal and MarshalBinary compare and encode cells only, not the marks.
+
+// Forget marks cell (x, y) unknown and clears it to Empty.
+func (g *Grid2d) Forget(x, y int) {
+  if !g.InBoundsXY(x, y) {
+    panic("out of bounds access")
+  }
+  i, bit := g.index(x, y)
+  g.set(i, bit, Empty)
+  if g.unknown == nil {
+    g.unknown = make([]uint64, len(g.planes[0]))
+  }
+  g.unknown[i] |= 1 << bit
+}
+
+// KnownXY reports whether cell (x, y) is known.
+func (g *Grid2d) KnownXY(x, y int) bool {
+  if !g.InBoundsXY(x, y) {
+    panic("out of bounds access")
+  }
+  if g.unknown == nil {
+    return true
+  }
+  i, bit := g.index(x, y)
+  return g.unknown[i] >> bit & 1 == 0
+}
+
+func (g *Grid2d) Known(p Pos) bool {
+  return g.KnownXY(p.X, p.Y)
+}
+
+// Partial reports whether any cell is unknown.
+func (g *Grid2d) Partial() bool {
+  for _, word := range g.unknown {
+    if word != 0 {
+      return true
+    }
+  }
+  return false
+}
+
+// markKnown clears the unknown mark of bit of word i.
+func (g *Grid2d) markKnown(i int, bit uint) {
+  if g.unknown != nil {
+    g.unknown[i] &^= 1 << bit
+  }
+}
@@ -1,8 +1,27 @@
 package universes
 import "oscarkilo.com/inteluni/substrates"
 
+func init() {
+  Register(Spec{
+    Name:   "gameoflife",
+    Doc:    "Conway's Life, B3/S23",
+    Params: []Param{complexityParam},
+    New: func(W, H int, p Params, rng *substrates.SplitMix64) Universe {
+      return NewConwayUniverse(W, H, p["complexity"].(int), rng)
+    },
+    Restore: func(
+        g *substrates.Grid2d,
+        p Params,
+        _ *substrates.SplitMix64,
+    ) (Universe, error) {
+      return &ConwayUniverse{grid: g, complexity: p["complexity"].(int)}, nil
+    },
+  })
+}
+
 type ConwayUniverse struct {
-  grid *substrates.Grid2d
+  grid       *substrates.Grid2d
+  complexity int  // initial percent alive, kept for Snapshot
 }
 
 func NewConwayUniverse(
@@ -15,7 +34,8 @@ func NewConwayUniverse(
   }
   g := substrates.NewGrid2d(W, H)
   u := &ConwayUniverse{
-    grid: g,
+    grid:       g,
+    complexity: initialComplexity,
   }
   u.seedInitialState(initialComplexity, rng)
   return u
@@ -37,23 +57,14 @@ func (u *ConwayUniverse) Grid() *substrates.Grid2d {
   return u.grid
 }
 
+// B3/S23 as neighbor-count masks for substrates.LifeStep.
+const (
+  conwayBirth   uint16 = 1<<3
+  conwaySurvive uint16 = 1<<2 | 1<<3
+)
+
 func (u *ConwayUniverse) Advance() {
-  nextGrid := u.grid.Clone()
-  width, height := u.grid.W(), u.grid.H()
-  for x := 0; x < width; x++ {
-    for y := 0; y < height; y++ {
-      liveNeighbors := u.countLiveNeighbors(x, y)
-      currentState := u.grid.XY(x, y)
-      if currentState == 1 && (liveNeighbors < 2 || liveNeighbors > 3) {
-        nextGrid.SetXY(x, y, 0) // Cell dies
-      } else if currentState == 0 && liveNeighbors == 3 {
-        nextGrid.SetXY(x, y, 1) // Cell becomes alive
-      } else {
-        nextGrid.SetXY(x, y, currentState) // State remains the same
-      }
-    }
-  }
-  u.grid = nextGrid
+  u.grid = substrates.LifeStep(u.grid, conwayBirth, conwaySurvive)
 }
 
 /*
@@ -73,20 +84,22 @@ func (u *ConwayUniverse) countLiveNeighbors(x, y int) int {
   return liveCount
 }
 */
+// countLiveNeighbors is the cell-by-cell count that Advance computes a
+// word at a time; neighbors past the edges follow the grid topology.
 func (u *ConwayUniverse) countLiveNeighbors(x, y int) int {
-  w, h := u.grid.W(), u.grid.H()
-  xm1 := (x - 1 + w) % w
-  xp1 := (x + 1) % w
-  ym1 := (y - 1 + h) % h
-  yp1 := (y + 1) % h
-  return u.grid.XY(xm1, ym1) +
-         u.grid.XY(x,   ym1) +
-         u.grid.XY(xp1, ym1) +
-         u.grid.XY(xm1, y)   +
-         u.grid.XY(xp1, y)   +
-         u.grid.XY(xm1, yp1) +
-         u.grid.XY(x,   yp1) +
-         u.grid.XY(xp1, yp1)
+  liveCount := 0
+  for dy := -1; dy <= 1; dy++ {
+    for dx := -1; dx <= 1; dx++ {
+      if dx == 0 && dy == 0 {
+        continue // Skip the cell itself
+      }
+      nx, ny, ok := u.grid.Resolve(x+dx, y+dy)
+      if ok && u.grid.XY(nx, ny) == substrates.Live {
+        liveCount++
+      }
+    }
+  }
+  return liveCount
 }
 
 func (u *ConwayUniverse) MakeEvolver() substrates.Evolver {
@@ -103,3 +116,7 @@ func (u *ConwayUniverse) MakeEvolver() substrates.Evolver {
 func (u *ConwayUniverse) Deterministic() bool {
   return true
 }
+
+func (u *ConwayUniverse) Snapshot() Snapshot {
+  return snapshot("gameoflife", Params{"complexity": u.complexity}, u.grid, nil)
+}
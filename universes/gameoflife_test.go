@@ -238,3 +238,34 @@ func TestCountLiveNeighbors_LargePattern(t *testing.T) {
     }
   }
 }
+
+func TestCountLiveNeighbors_BoundedEdge(t *testing.T) {
+  u := makeTestUniverse(3, 3)
+  u.grid.SetTopology(substrates.Bounded)
+  u.grid.SetXY(2, 2, 1)
+  if count := u.countLiveNeighbors(0, 0); count != 0 {
+    t.Errorf("bounded grid should not wrap, got %d neighbors", count)
+  }
+}
+
+func TestAdvance_BoundedBlinkerAtEdge(t *testing.T) {
+  // A horizontal blinker along the top edge of a bounded grid loses
+  // its upper half: only the cell below the center is born.
+  u := makeTestUniverse(5, 5)
+  u.grid.SetTopology(substrates.Bounded)
+  u.grid.SetXY(1, 0, 1)
+  u.grid.SetXY(2, 0, 1)
+  u.grid.SetXY(3, 0, 1)
+  u.Advance()
+  for y := 0; y < 5; y++ {
+    for x := 0; x < 5; x++ {
+      want := 0
+      if x == 2 && (y == 0 || y == 1) {
+        want = 1
+      }
+      if got := u.Grid().XY(x, y); got != want {
+        t.Fatalf("cell (%d,%d): expected %d, got %d", x, y, want, got)
+      }
+    }
+  }
+}
@@ -1,6 +1,33 @@
 package universes
 import "oscarkilo.com/inteluni/substrates"
 
+func init() {
+  Register(Spec{
+    Name:   "gameofnoise",
+    Doc:    "Conway's Life followed by noise",
+    Params: []Param{noiseParam, complexityParam},
+    New: func(W, H int, p Params, rng *substrates.SplitMix64) Universe {
+      return NewGameOfNoiseUniverse(
+          W, H, p["noise"].(float64), p["complexity"].(int), rng)
+    },
+    Restore: func(
+        g *substrates.Grid2d,
+        p Params,
+        rng *substrates.SplitMix64,
+    ) (Universe, error) {
+      if rng == nil {
+        return nil, needRNG("gameofnoise")
+      }
+      return &GameOfNoiseUniverse{
+        grid:       g,
+        noise:      p["noise"].(float64),
+        complexity: p["complexity"].(int),
+        rand:       rng,
+      }, nil
+    },
+  })
+}
+
 type GameOfNoiseUniverse struct {
   grid          *substrates.Grid2d
   noise         float64 // 0.0 to 1.0
@@ -84,3 +111,10 @@ func (u *GameOfNoiseUniverse) MakeEvolver() substrates.Evolver {
 func (u *GameOfNoiseUniverse) Deterministic() bool {
   return u.noise == 0.0
 }
+
+func (u *GameOfNoiseUniverse) Snapshot() Snapshot {
+  return snapshot("gameofnoise", Params{
+    "noise":      u.noise,
+    "complexity": u.complexity,
+  }, u.grid, u.rand)
+}
@@ -0,0 +1,204 @@
+package universes
+import "fmt"
+import "strconv"
+import "strings"
+import "oscarkilo.com/inteluni/substrates"
+
+func init() {
+  Register(Spec{
+    Name: "generations",
+    Doc:  "Life-like rules with refractory states",
+    Params: []Param{
+      {
+        Name: "rule", Kind: StringParam, Doc: "S/B/C rulestring, e.g. /2/3",
+        Check: func(s string) error {
+          _, err := ParseGenerationsRule(s)
+          return err
+        },
+      },
+      complexityParam,
+    },
+    New: func(W, H int, p Params, rng *substrates.SplitMix64) Universe {
+      return NewGenerationsUniverse(
+          W, H, p["rule"].(string), p["complexity"].(int), rng)
+    },
+    Restore: func(
+        g *substrates.Grid2d,
+        p Params,
+        _ *substrates.SplitMix64,
+    ) (Universe, error) {
+      rule, err := ParseGenerationsRule(p["rule"].(string))
+      if err != nil {
+        return nil, err
+      }
+      return &GenerationsUniverse{
+        grid:       g,
+        rule:       rule,
+        complexity: p["complexity"].(int),
+      }, nil
+    },
+  })
+}
+
+// GenerationsRule extends a Life-like rule with refractory states:
+// a Live cell that fails to survive decays through states 2..States-1
+// before becoming Empty again, and cannot be reborn while decaying.
+type GenerationsRule struct {
+  Birth   uint16  // bit n: Empty cell with n Live neighbors is born
+  Survive uint16  // bit n: Live cell with n Live neighbors survives
+  States  int     // total states including Empty and Live, >= 2
+}
+
+// maxGenerationsStates keeps cell states within a handful of bit planes.
+const maxGenerationsStates = 256
+
+// ParseGenerationsRule reads a rulestring in S/B/C notation, e.g.
+// "345/2/4" (Star Wars) or "/2/3" (Brian's Brain), or the equivalent
+// lettered form "B2/S345/C4" with parts in any order.
+func ParseGenerationsRule(s string) (GenerationsRule, error) {
+  parts := strings.Split(strings.ToUpper(strings.TrimSpace(s)), "/")
+  if len(parts) != 3 {
+    return GenerationsRule{}, fmt.Errorf(
+        "rule %q: want S/B/C or B<digits>/S<digits>/C<states>", s)
+  }
+  lettered := false
+  for _, part := range parts {
+    if part != "" && (part[0] == 'B' || part[0] == 'S' || part[0] == 'C') {
+      lettered = true
+    }
+  }
+  if !lettered {
+    parts = []string{"S" + parts[0], "B" + parts[1], "C" + parts[2]}
+  }
+  var r GenerationsRule
+  var seen [3]bool
+  for _, part := range parts {
+    if part == "" {
+      return GenerationsRule{}, fmt.Errorf("rule %q: empty part", s)
+    }
+    tag, body := part[0], part[1:]
+    slot := strings.IndexByte("BSC", tag)
+    if slot < 0 {
+      return GenerationsRule{}, fmt.Errorf(
+          "rule %q: part %q is not B, S or C", s, part)
+    }
+    if seen[slot] {
+      return GenerationsRule{}, fmt.Errorf("rule %q: %c given twice", s, tag)
+    }
+    seen[slot] = true
+    var err error
+    switch tag {
+      case 'B':
+        r.Birth, err = neighborMask(body)
+      case 'S':
+        r.Survive, err = neighborMask(body)
+      case 'C':
+        r.States, err = strconv.Atoi(body)
+        if err == nil &&
+            (r.States < 2 || r.States > maxGenerationsStates) {
+          err = fmt.Errorf("state count %d out of 2..%d",
+              r.States, maxGenerationsStates)
+        }
+    }
+    if err != nil {
+      return GenerationsRule{}, fmt.Errorf("rule %q: %v", s, err)
+    }
+  }
+  return r, nil
+}
+
+// String gives the canonical S/B/C rulestring, e.g. "/2/3".
+func (r GenerationsRule) String() string {
+  var b strings.Builder
+  writeNeighborDigits(&b, r.Survive)
+  b.WriteByte('/')
+  writeNeighborDigits(&b, r.Birth)
+  b.WriteByte('/')
+  b.WriteString(strconv.Itoa(r.States))
+  return b.String()
+}
+
+// BriansBrainRule is B2/S/C3: every Live cell decays after one tick.
+const BriansBrainRule = "/2/3"
+
+type GenerationsUniverse struct {
+  grid       *substrates.Grid2d
+  rule       GenerationsRule
+  complexity int  // initial percent alive, kept for Snapshot
+}
+
+func NewGenerationsUniverse(
+    W, H int,
+    rule string,            // e.g. "345/2/4"
+    initialComplexity int,  // percent of cells Live at start
+    rng *substrates.SplitMix64,
+) Universe {
+  parsed, err := ParseGenerationsRule(rule)
+  if err != nil {
+    panic(err.Error())
+  }
+  if initialComplexity < 0 || initialComplexity > 100 {
+    panic("initialComplexity must be between 0 and 100")
+  }
+  u := &GenerationsUniverse{
+    grid:       substrates.NewGrid2d(W, H),
+    rule:       parsed,
+    complexity: initialComplexity,
+  }
+  u.seedInitialState(initialComplexity, rng)
+  return u
+}
+
+func NewBriansBrainUniverse(
+    W, H int,
+    initialComplexity int,
+    rng *substrates.SplitMix64,
+) Universe {
+  return NewGenerationsUniverse(W, H, BriansBrainRule, initialComplexity, rng)
+}
+
+func (u *GenerationsUniverse) seedInitialState(
+    complexityPercent int, rng *substrates.SplitMix64) {
+  fillProb := float64(complexityPercent) / 100.0
+  u.grid.Map(func(x, y, _ int) int {
+    if rng.Float64() < fillProb {
+      return substrates.Live
+    }
+    return substrates.Empty
+  })
+}
+
+func (u *GenerationsUniverse) Rule() GenerationsRule {
+  return u.rule
+}
+
+func (u *GenerationsUniverse) Grid() *substrates.Grid2d {
+  return u.grid
+}
+
+func (u *GenerationsUniverse) Advance() {
+  u.grid = substrates.GenerationsStep(
+      u.grid, u.rule.Birth, u.rule.Survive, u.rule.States)
+}
+
+func (u *GenerationsUniverse) MakeEvolver() substrates.Evolver {
+  rule := u.rule
+  return func(
+      src *substrates.Grid2d,
+      _ *substrates.SplitMix64,
+  ) *substrates.Grid2d {
+    return substrates.GenerationsStep(
+        src, rule.Birth, rule.Survive, rule.States)
+  }
+}
+
+func (u *GenerationsUniverse) Deterministic() bool {
+  return true
+}
+
+func (u *GenerationsUniverse) Snapshot() Snapshot {
+  return snapshot("generations", Params{
+    "rule":       u.rule.String(),
+    "complexity": u.complexity,
+  }, u.grid, nil)
+}
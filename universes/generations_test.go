@@ -0,0 +1,70 @@
+package universes
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+func TestParseGenerationsRule(t *testing.T) {
+  cases := []struct {
+    in    string
+    want  GenerationsRule
+    canon string
+  }{
+    {"/2/3", GenerationsRule{Birth: 1<<2, States: 3}, "/2/3"},
+    {"345/2/4", GenerationsRule{
+        Birth: 1<<2, Survive: 1<<3 | 1<<4 | 1<<5, States: 4}, "345/2/4"},
+    {"b2/s345/c4", GenerationsRule{
+        Birth: 1<<2, Survive: 1<<3 | 1<<4 | 1<<5, States: 4}, "345/2/4"},
+  }
+  for _, c := range cases {
+    r, err := ParseGenerationsRule(c.in)
+    if err != nil {
+      t.Fatalf("%q: unexpected error %v", c.in, err)
+    }
+    if r != c.want {
+      t.Errorf("%q: got %+v, want %+v", c.in, r, c.want)
+    }
+    if r.String() != c.canon {
+      t.Errorf("%q: canonical form %q, want %q", c.in, r.String(), c.canon)
+    }
+  }
+  for _, in := range []string{"/2", "/2/1", "/2/x", "B2/B3/C3", "9/2/3"} {
+    if _, err := ParseGenerationsRule(in); err == nil {
+      t.Errorf("%q: expected error", in)
+    }
+  }
+}
+
+func TestBriansBrain_StatesStayInRange(t *testing.T) {
+  u := NewBriansBrainUniverse(20, 20, 30, substrates.NewSplitMix64(8))
+  sawRefractory := false
+  for step := 0; step < 10; step++ {
+    u.Advance()
+    u.Grid().Map(func(x, y, val int) int {
+      if val < 0 || val > 2 {
+        t.Fatalf("state %d at (%d,%d) outside 0..2", val, x, y)
+      }
+      if val == 2 {
+        sawRefractory = true
+      }
+      return val
+    })
+  }
+  if !sawRefractory {
+    t.Errorf("Brian's Brain produced no refractory cells")
+  }
+}
+
+func TestGenerations_EvolverMatchesAdvance(t *testing.T) {
+  u := NewGenerationsUniverse(12, 12, "345/2/4", 40, substrates.NewSplitMix64(2))
+  evolve := u.MakeEvolver()
+  for step := 0; step < 5; step++ {
+    before := u.Grid().Clone()
+    next := evolve(u.Grid(), nil)
+    if !u.Grid().Equal(before) {
+      t.Fatalf("evolver modified its input")
+    }
+    u.Advance()
+    if !next.Equal(u.Grid()) {
+      t.Fatalf("step %d: evolver and Advance disagree", step)
+    }
+  }
+}
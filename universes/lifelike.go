@@ -0,0 +1,189 @@
+package universes
+import "fmt"
+import "strings"
+import "oscarkilo.com/inteluni/substrates"
+
+func init() {
+  Register(Spec{
+    Name: "lifelike",
+    Doc:  "any outer-totalistic B/S rule",
+    Params: []Param{
+      {
+        Name: "rule", Kind: StringParam, Doc: "B/S rulestring, e.g. B36/S23",
+        Check: func(s string) error {
+          _, err := ParseLifeRule(s)
+          return err
+        },
+      },
+      complexityParam,
+    },
+    New: func(W, H int, p Params, rng *substrates.SplitMix64) Universe {
+      return NewLifeLikeUniverse(
+          W, H, p["rule"].(string), p["complexity"].(int), rng)
+    },
+    Restore: func(
+        g *substrates.Grid2d,
+        p Params,
+        _ *substrates.SplitMix64,
+    ) (Universe, error) {
+      rule, err := ParseLifeRule(p["rule"].(string))
+      if err != nil {
+        return nil, err
+      }
+      return &LifeLikeUniverse{
+        grid:       g,
+        rule:       rule,
+        complexity: p["complexity"].(int),
+      }, nil
+    },
+  })
+}
+
+// LifeRule is an outer-totalistic rule on the Moore neighborhood, kept
+// as the neighbor-count masks understood by substrates.LifeStep.
+type LifeRule struct {
+  Birth   uint16  // bit n: dead cell with n live neighbors is born
+  Survive uint16  // bit n: live cell with n live neighbors survives
+}
+
+// ParseLifeRule reads a rulestring in B/S notation, e.g. "B3/S23"
+// (Conway), "B36/S23" (HighLife), "B2/S" (Seeds) or "B3678/S34678"
+// (Day & Night).  Case is ignored and the parts may come in either
+// order.
+func ParseLifeRule(s string) (LifeRule, error) {
+  parts := strings.Split(strings.ToUpper(strings.TrimSpace(s)), "/")
+  if len(parts) != 2 {
+    return LifeRule{}, fmt.Errorf("rule %q: want B<digits>/S<digits>", s)
+  }
+  var r LifeRule
+  var seen [2]bool
+  for _, part := range parts {
+    if part == "" {
+      return LifeRule{}, fmt.Errorf("rule %q: empty part", s)
+    }
+    mask, err := neighborMask(part[1:])
+    if err != nil {
+      return LifeRule{}, fmt.Errorf("rule %q: %v", s, err)
+    }
+    switch part[0] {
+      case 'B':
+        if seen[0] {
+          return LifeRule{}, fmt.Errorf("rule %q: B given twice", s)
+        }
+        seen[0] = true
+        r.Birth = mask
+      case 'S':
+        if seen[1] {
+          return LifeRule{}, fmt.Errorf("rule %q: S given twice", s)
+        }
+        seen[1] = true
+        r.Survive = mask
+      default:
+        return LifeRule{}, fmt.Errorf("rule %q: part %q is not B or S", s, part)
+    }
+  }
+  return r, nil
+}
+
+// neighborMask turns digits such as "23" into a neighbor-count mask.
+func neighborMask(digits string) (uint16, error) {
+  var mask uint16
+  for _, d := range digits {
+    if d < '0' || d > '8' {
+      return 0, fmt.Errorf("neighbor count %q out of 0..8", d)
+    }
+    mask |= 1 << uint(d-'0')
+  }
+  return mask, nil
+}
+
+// String gives the canonical rulestring, e.g. "B36/S23".
+func (r LifeRule) String() string {
+  var b strings.Builder
+  b.WriteByte('B')
+  writeNeighborDigits(&b, r.Birth)
+  b.WriteString("/S")
+  writeNeighborDigits(&b, r.Survive)
+  return b.String()
+}
+
+func writeNeighborDigits(b *strings.Builder, mask uint16) {
+  for n := 0; n <= 8; n++ {
+    if mask>>uint(n)&1 == 1 {
+      b.WriteByte('0' + byte(n))
+    }
+  }
+}
+
+// LifeLikeUniverse runs any B/S rule; ConwayUniverse is its B3/S23 case.
+type LifeLikeUniverse struct {
+  grid       *substrates.Grid2d
+  rule       LifeRule
+  complexity int  // initial percent alive, kept for Snapshot
+}
+
+func NewLifeLikeUniverse(
+    W, H int,
+    rule string,            // e.g. "B36/S23"
+    initialComplexity int,  // percent of cells alive at start
+    rng *substrates.SplitMix64,
+) Universe {
+  parsed, err := ParseLifeRule(rule)
+  if err != nil {
+    panic(err.Error())
+  }
+  if initialComplexity < 0 || initialComplexity > 100 {
+    panic("initialComplexity must be between 0 and 100")
+  }
+  u := &LifeLikeUniverse{
+    grid:       substrates.NewGrid2d(W, H),
+    rule:       parsed,
+    complexity: initialComplexity,
+  }
+  u.seedInitialState(initialComplexity, rng)
+  return u
+}
+
+func (u *LifeLikeUniverse) seedInitialState(
+    complexityPercent int, rng *substrates.SplitMix64) {
+  fillProb := float64(complexityPercent) / 100.0
+  u.grid.Map(func(x, y, _ int) int {
+    if rng.Float64() < fillProb {
+      return 1 // Live cell
+    }
+    return 0 // Dead cell
+  })
+}
+
+func (u *LifeLikeUniverse) Rule() LifeRule {
+  return u.rule
+}
+
+func (u *LifeLikeUniverse) Grid() *substrates.Grid2d {
+  return u.grid
+}
+
+func (u *LifeLikeUniverse) Advance() {
+  u.grid = substrates.LifeStep(u.grid, u.rule.Birth, u.rule.Survive)
+}
+
+func (u *LifeLikeUniverse) MakeEvolver() substrates.Evolver {
+  rule := u.rule
+  return func(
+      src *substrates.Grid2d,
+      _ *substrates.SplitMix64,
+  ) *substrates.Grid2d {
+    return substrates.LifeStep(src, rule.Birth, rule.Survive)
+  }
+}
+
+func (u *LifeLikeUniverse) Deterministic() bool {
+  return true
+}
+
+func (u *LifeLikeUniverse) Snapshot() Snapshot {
+  return snapshot("lifelike", Params{
+    "rule":       u.rule.String(),
+    "complexity": u.complexity,
+  }, u.grid, nil)
+}
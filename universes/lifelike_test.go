@@ -0,0 +1,88 @@
+package universes
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+func TestParseLifeRule(t *testing.T) {
+  cases := []struct {
+    in      string
+    birth   uint16
+    survive uint16
+    canon   string
+  }{
+    {"B3/S23", 1<<3, 1<<2 | 1<<3, "B3/S23"},
+    {"b36/s23", 1<<3 | 1<<6, 1<<2 | 1<<3, "B36/S23"},
+    {"B2/S", 1<<2, 0, "B2/S"},
+    {"S34678/B3678", 1<<3 | 1<<6 | 1<<7 | 1<<8,
+        1<<3 | 1<<4 | 1<<6 | 1<<7 | 1<<8, "B3678/S34678"},
+  }
+  for _, c := range cases {
+    r, err := ParseLifeRule(c.in)
+    if err != nil {
+      t.Fatalf("%q: unexpected error %v", c.in, err)
+    }
+    if r.Birth != c.birth || r.Survive != c.survive {
+      t.Errorf("%q: got B%b/S%b", c.in, r.Birth, r.Survive)
+    }
+    if r.String() != c.canon {
+      t.Errorf("%q: canonical form %q, want %q", c.in, r.String(), c.canon)
+    }
+  }
+}
+
+func TestParseLifeRule_Invalid(t *testing.T) {
+  for _, in := range []string{"", "B3", "B3/S23/C2", "B9/S23", "B3/B2", "X3/S2"} {
+    if _, err := ParseLifeRule(in); err == nil {
+      t.Errorf("%q: expected error", in)
+    }
+  }
+}
+
+func TestLifeLike_ConwayRuleMatchesConway(t *testing.T) {
+  rng := substrates.NewSplitMix64(5)
+  c := NewConwayUniverse(16, 12, 35, rng.Clone())
+  l := NewLifeLikeUniverse(16, 12, "B3/S23", 35, rng.Clone())
+  for step := 0; step < 10; step++ {
+    if !c.Grid().Equal(l.Grid()) {
+      t.Fatalf("step %d: B3/S23 diverged from ConwayUniverse", step)
+    }
+    c.Advance()
+    l.Advance()
+  }
+}
+
+func TestLifeLike_SeedsKillsEveryLiveCell(t *testing.T) {
+  g := substrates.NewGrid2d(5, 5)
+  g.SetXY(1, 1, 1)
+  g.SetXY(2, 1, 1)
+  u := &LifeLikeUniverse{grid: g, rule: LifeRule{Birth: 1<<2}}
+  u.Advance()
+  if u.Grid().XY(1, 1) != 0 || u.Grid().XY(2, 1) != 0 {
+    t.Errorf("Seeds has no survival; live cells should die")
+  }
+  if u.Grid().XY(1, 0) != 1 || u.Grid().XY(2, 2) != 1 {
+    t.Errorf("cells with two live neighbors should be born")
+  }
+}
+
+func TestLifeLike_EvolverLeavesInputUnchanged(t *testing.T) {
+  rng := substrates.NewSplitMix64(9)
+  u := NewLifeLikeUniverse(8, 8, "B36/S23", 40, rng)
+  before := u.Grid().Clone()
+  next := u.MakeEvolver()(u.Grid(), nil)
+  if !u.Grid().Equal(before) {
+    t.Errorf("evolver modified its input")
+  }
+  u.Advance()
+  if !next.Equal(u.Grid()) {
+    t.Errorf("evolver and Advance disagree")
+  }
+}
+
+func TestLifeLike_BadRulePanics(t *testing.T) {
+  defer func() {
+    if r := recover(); r == nil {
+      t.Fatalf("expected panic on malformed rule")
+    }
+  }()
+  NewLifeLikeUniverse(4, 4, "B3", 10, substrates.NewSplitMix64(1))
+}
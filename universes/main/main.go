@@ -1,9 +1,26 @@
+// Command main prints a few ticks of any registered universe:
+//
+//   go run ./universes/main -universe lifelike -params rule=B36/S23,complexity=30
+//   go run ./universes/main -list
 package main
-import "time"
+import "flag"
 import "fmt"
+import "os"
+import "time"
 import "oscarkilo.com/inteluni/substrates"
 import "oscarkilo.com/inteluni/universes"
 
+var universeFlag = flag.String(
+    "universe", "noisy", "universe type, see -list",)
+var paramsFlag = flag.String(
+    "params", "noise=0.2,complexity=30", "universe parameters, name=value,...",)
+var widthFlag = flag.Int("width", 10, "grid width",)
+var heightFlag = flag.Int("height", 6, "grid height",)
+var stepsFlag = flag.Int("steps", 5, "ticks to print",)
+var seedFlag = flag.Uint64("seed", 0, "random seed, default: clock",)
+var listFlag = flag.Bool(
+    "list", false, "list universe types and their parameters",)
+
 func printGrid(g *substrates.Grid2d) {
   for y := 0; y < g.H(); y++ {
     for x := 0; x < g.W(); x++ {
@@ -14,30 +31,58 @@ func printGrid(g *substrates.Grid2d) {
         case 1:
           fmt.Print("#")
         default:
-          emsg := fmt.Sprintf("unexpected %d at (%d, %d)", val, x, y)
-          panic(emsg)
+          fmt.Print("+") // refractory
       }
     }
     fmt.Println()
   }
 }
 
+func list() {
+  for _, name := range universes.Names() {
+    spec, _ := universes.Lookup(name)
+    fmt.Printf("%s: %s\n", name, spec.Doc)
+    for _, p := range spec.Params {
+      fmt.Printf("  %s (%v", p.Name, p.Kind)
+      if p.Kind != universes.StringParam {
+        fmt.Printf(", %v..%v", p.Min, p.Max)
+      }
+      if p.Default != nil {
+        fmt.Printf(", default %v", p.Default)
+      }
+      fmt.Printf("): %s\n", p.Doc)
+    }
+  }
+}
+
 func main() {
-  width := 10
-  height := 6
-  noise := 0.2
-  complexity := 30
-  seed := time.Now().UnixNano()
-  rng := substrates.NewSplitMix64(uint64(seed))
+  flag.Parse()
+  if *listFlag {
+    list()
+    return
+  }
+  seed := *seedFlag
+  if seed == 0 {
+    seed = uint64(time.Now().UnixNano())
+  }
+  rng := substrates.NewSplitMix64(seed)
 
-  u := universes.NewNoisyUniverse(
-      width, height, noise, complexity, rng)
+  params, err := universes.ParseParams(*paramsFlag)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(2)
+  }
+  u, err := universes.New(*universeFlag, *widthFlag, *heightFlag, params, rng)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
 
   fmt.Println("initial grid:")
   printGrid(u.Grid())
   fmt.Println()
 
-  for i := 0; i < 5; i++ {
+  for i := 0; i < *stepsFlag; i++ {
     fmt.Println("iteration ", i+1)
     u.Advance()
     printGrid(u.Grid())
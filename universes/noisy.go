@@ -1,6 +1,33 @@
 package universes
 import "oscarkilo.com/inteluni/substrates"
 
+func init() {
+  Register(Spec{
+    Name:   "noisy",
+    Doc:    "static obstacles redrawn at random",
+    Params: []Param{noiseParam, complexityParam},
+    New: func(W, H int, p Params, rng *substrates.SplitMix64) Universe {
+      return NewNoisyUniverse(
+          W, H, p["noise"].(float64), p["complexity"].(int), rng)
+    },
+    Restore: func(
+        g *substrates.Grid2d,
+        p Params,
+        rng *substrates.SplitMix64,
+    ) (Universe, error) {
+      if rng == nil {
+        return nil, needRNG("noisy")
+      }
+      return &NoisyUniverse{
+        grid:       g,
+        noise:      p["noise"].(float64),
+        complexity: p["complexity"].(int),
+        rand:       rng,
+      }, nil
+    },
+  })
+}
+
 type NoisyUniverse struct {
   grid       *substrates.Grid2d
   noise      float64 // 0.0 to 1.0
@@ -85,3 +112,10 @@ func (u *NoisyUniverse) MakeEvolver() substrates.Evolver {
 func (u *NoisyUniverse) Deterministic() bool {
   return u.noise == 0.0
 }
+
+func (u *NoisyUniverse) Snapshot() Snapshot {
+  return snapshot("noisy", Params{
+    "noise":      u.noise,
+    "complexity": u.complexity,
+  }, u.grid, u.rand)
+}
@@ -0,0 +1,224 @@
+package universes
+import "fmt"
+import "math"
+import "sort"
+import "strconv"
+import "strings"
+import "oscarkilo.com/inteluni/substrates"
+
+// ---------- Registry ----------
+// Every universe type registers a Spec under its name, so that tools
+// can build any of them from a name and a parameter map, e.g.
+//
+//   u, err := universes.New("lifelike", 32, 32, universes.Params{
+//     "rule": "B36/S23", "complexity": 20,
+//   }, rng)
+//
+// instead of calling each constructor with its own signature.
+
+// ParamKind is the type of a universe parameter's value.
+type ParamKind int
+
+const (
+  FloatParam ParamKind = iota
+  IntParam
+  StringParam
+)
+
+var paramKindNames = []string{"float", "int", "string"}
+
+func (k ParamKind) String() string {
+  if k < 0 || int(k) >= len(paramKindNames) {
+    return fmt.Sprintf("ParamKind(%d)", int(k))
+  }
+  return paramKindNames[k]
+}
+
+// Param describes one parameter of a universe type.
+type Param struct {
+  Name     string
+  Kind     ParamKind
+  Doc      string
+  Min, Max float64             // inclusive range of numeric parameters
+  Default  any                 // nil: the parameter is required
+  Check    func(string) error  // validates string parameters, if set
+}
+
+// Params maps parameter names to values: float64, int or string, or a
+// string holding a number, as parsed from the command line.
+type Params map[string]any
+
+// Spec is a registered universe type.
+type Spec struct {
+  Name   string
+  Doc    string
+  Params []Param
+  // New builds the universe from parameters already checked against
+  // Params, with defaults filled in and numbers of their own kind.
+  New    func(W, H int, p Params, rng *substrates.SplitMix64) Universe
+  // Restore rebuilds a universe from a Snapshot's grid, checked
+  // parameters and rng, which is nil if the snapshot had none.
+  Restore func(
+      g *substrates.Grid2d,
+      p Params,
+      rng *substrates.SplitMix64,
+  ) (Universe, error)
+}
+
+var registry = map[string]Spec{}
+
+// Register adds a universe type.  It panics if the name is taken, and
+// is meant to be called from init functions.
+func Register(s Spec) {
+  if _, dup := registry[s.Name]; dup {
+    panic("universe registered twice: " + s.Name)
+  }
+  registry[s.Name] = s
+}
+
+// Names lists the registered universe types, sorted.
+func Names() []string {
+  names := make([]string, 0, len(registry))
+  for name := range registry {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+// Lookup returns the Spec registered under name.
+func Lookup(name string) (Spec, error) {
+  s, ok := registry[name]
+  if !ok {
+    return Spec{}, fmt.Errorf("unknown universe %q (have %s)",
+        name, strings.Join(Names(), ", "))
+  }
+  return s, nil
+}
+
+// New builds a W×H universe of the named type.
+func New(
+    name string,
+    W, H int,
+    p Params,
+    rng *substrates.SplitMix64,
+) (Universe, error) {
+  s, err := Lookup(name)
+  if err != nil {
+    return nil, err
+  }
+  checked, err := s.Check(p)
+  if err != nil {
+    return nil, err
+  }
+  return s.New(W, H, checked, rng), nil
+}
+
+// Check validates p against the schema: no unknown names, every
+// required parameter present, values of the right kind and in range.
+// It returns a copy with defaults filled in and every value converted
+// to its parameter's kind.
+func (s Spec) Check(p Params) (Params, error) {
+  out := Params{}
+  known := map[string]bool{}
+  for _, param := range s.Params {
+    known[param.Name] = true
+    v, ok := p[param.Name]
+    if !ok {
+      if param.Default == nil {
+        return nil, fmt.Errorf("universe %q needs %s", s.Name, param.Name)
+      }
+      v = param.Default
+    }
+    v, err := param.convert(v)
+    if err != nil {
+      return nil, fmt.Errorf("universe %q: %v", s.Name, err)
+    }
+    out[param.Name] = v
+  }
+  for name := range p {
+    if !known[name] {
+      return nil, fmt.Errorf("universe %q takes no %s", s.Name, name)
+    }
+  }
+  return out, nil
+}
+
+// convert turns v into the parameter's kind and checks it.
+func (param Param) convert(v any) (any, error) {
+  if param.Kind == StringParam {
+    str, ok := v.(string)
+    if !ok {
+      return nil, fmt.Errorf("%s: %v is a %T, want a string",
+          param.Name, v, v)
+    }
+    if param.Check != nil {
+      if err := param.Check(str); err != nil {
+        return nil, err
+      }
+    }
+    return str, nil
+  }
+  var f float64
+  switch x := v.(type) {
+    case float64:
+      f = x
+    case int:
+      f = float64(x)
+    case string:
+      parsed, err := strconv.ParseFloat(x, 64)
+      if err != nil {
+        return nil, fmt.Errorf("%s %q is not a number", param.Name, x)
+      }
+      f = parsed
+    default:
+      return nil, fmt.Errorf("%s: %v is a %T, want %v",
+          param.Name, v, v, param.Kind)
+  }
+  // written so that NaN, which compares false, is out of range
+  if !(f >= param.Min && f <= param.Max) {
+    return nil, fmt.Errorf("%s %v outside %v..%v",
+        param.Name, f, param.Min, param.Max)
+  }
+  if param.Kind == IntParam {
+    if f != math.Trunc(f) {
+      return nil, fmt.Errorf("%s %v is not an integer", param.Name, f)
+    }
+    return int(f), nil
+  }
+  return f, nil
+}
+
+// ParseParams reads "name=value,name=value" as given on a command
+// line.  Values stay strings; Spec.Check converts them.
+func ParseParams(s string) (Params, error) {
+  p := Params{}
+  if strings.TrimSpace(s) == "" {
+    return p, nil
+  }
+  for _, kv := range strings.Split(s, ",") {
+    name, value, ok := strings.Cut(kv, "=")
+    name = strings.TrimSpace(name)
+    if !ok || name == "" {
+      return nil, fmt.Errorf("parameter %q: want name=value", kv)
+    }
+    if _, dup := p[name]; dup {
+      return nil, fmt.Errorf("parameter %s given twice", name)
+    }
+    p[name] = strings.TrimSpace(value)
+  }
+  return p, nil
+}
+
+// The parameters shared by the built-in universes, with the ranges
+// their constructors enforce.
+var (
+  noiseParam = Param{
+    Name: "noise", Kind: FloatParam, Min: 0, Max: 1, Default: 0.0,
+    Doc: "probability that a cell is redrawn each tick",
+  }
+  complexityParam = Param{
+    Name: "complexity", Kind: IntParam, Min: 0, Max: 100,
+    Doc: "percent of cells occupied at start",
+  }
+)
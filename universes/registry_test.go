@@ -0,0 +1,146 @@
+package universes
+import "encoding/json"
+import "math"
+import "reflect"
+import "testing"
+import "oscarkilo.com/inteluni/substrates"
+
+func TestRegistryNames(t *testing.T) {
+  want := []string{
+    "gameoflife", "gameofnoise", "generations", "lifelike", "noisy",
+  }
+  if got := Names(); !reflect.DeepEqual(got, want) {
+    t.Errorf("Names() = %v", got)
+  }
+}
+
+func TestNewMatchesConstructor(t *testing.T) {
+  u, err := New("lifelike", 12, 9, Params{
+    "rule": "B36/S23", "complexity": 30.0,
+  }, substrates.NewSplitMix64(4))
+  if err != nil {
+    t.Fatal(err)
+  }
+  want := NewLifeLikeUniverse(12, 9, "B36/S23", 30, substrates.NewSplitMix64(4))
+  if !u.Grid().Equal(want.Grid()) {
+    t.Errorf("registry and constructor disagree")
+  }
+  u.Advance()
+  want.Advance()
+  if !u.Grid().Equal(want.Grid()) {
+    t.Errorf("registry and constructor disagree after a tick")
+  }
+}
+
+func TestSpecCheck(t *testing.T) {
+  noisy, err := Lookup("noisy")
+  if err != nil {
+    t.Fatal(err)
+  }
+  got, err := noisy.Check(Params{"complexity": "40"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if want := (Params{"noise": 0.0, "complexity": 40}); !reflect.DeepEqual(got, want) {
+    t.Errorf("checked %v, want %v", got, want)
+  }
+  bad := []struct {
+    name string
+    p    Params
+  }{
+    {"noisy", Params{}},                                  // no complexity
+    {"noisy", Params{"complexity": 101}},
+    {"noisy", Params{"complexity": 10, "noise": -0.1}},
+    {"noisy", Params{"complexity": 10, "noise": "NaN"}},
+    {"noisy", Params{"complexity": 10, "noise": math.NaN()}},
+    {"noisy", Params{"complexity": 2.5}},
+    {"noisy", Params{"complexity": "many"}},
+    {"noisy", Params{"complexity": 10, "rule": "B3/S23"}},
+    {"gameoflife", Params{"complexity": 10, "noise": 0.1}},
+    {"lifelike", Params{"complexity": 10, "rule": "B3"}},
+    {"lifelike", Params{"complexity": 10, "rule": 3}},
+    {"generations", Params{"complexity": 10}},
+    {"mars", Params{"complexity": 10}},
+  }
+  for _, c := range bad {
+    if _, err := New(c.name, 4, 4, c.p, substrates.NewSplitMix64(1)); err == nil {
+      t.Errorf("%s %v: expected error", c.name, c.p)
+    }
+  }
+}
+
+func TestParseParams(t *testing.T) {
+  p, err := ParseParams("rule=/2/3, complexity=20")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if want := (Params{"rule": "/2/3", "complexity": "20"}); !reflect.DeepEqual(p, want) {
+    t.Errorf("parsed %v", p)
+  }
+  if _, err := New("generations", 5, 5, p, substrates.NewSplitMix64(1)); err != nil {
+    t.Errorf("%v", err)
+  }
+  for _, s := range []string{"complexity", "=3", "a=1,a=2"} {
+    if _, err := ParseParams(s); err == nil {
+      t.Errorf("%q: expected error", s)
+    }
+  }
+}
+
+func TestSnapshotRestore(t *testing.T) {
+  params := map[string]Params{
+    "gameoflife":  {"complexity": 30},
+    "gameofnoise": {"noise": 0.1, "complexity": 30},
+    "generations": {"rule": "345/2/4", "complexity": 40},
+    "lifelike":    {"rule": "B36/S23", "complexity": 30},
+    "noisy":       {"noise": 0.3, "complexity": 30},
+  }
+  for _, name := range Names() {
+    u, err := New(name, 11, 7, params[name], substrates.NewSplitMix64(2))
+    if err != nil {
+      t.Fatal(err)
+    }
+    WithTopology(u, substrates.Reflective)
+    u.Advance()
+    // through JSON, as a checkpoint file would
+    data, err := json.Marshal(u.Snapshot())
+    if err != nil {
+      t.Fatalf("%s: %v", name, err)
+    }
+    var snap Snapshot
+    if err := json.Unmarshal(data, &snap); err != nil {
+      t.Fatalf("%s: %v", name, err)
+    }
+    restored, err := Restore(snap)
+    if err != nil {
+      t.Fatalf("%s: %v", name, err)
+    }
+    fork := Fork(u)
+    for tick := 0; tick < 5; tick++ {
+      u.Advance()
+      restored.Advance()
+      if !restored.Grid().Equal(u.Grid()) {
+        t.Fatalf("%s: restored universe diverged at tick %d", name, tick)
+      }
+    }
+    if restored.Grid().Topology() != substrates.Reflective {
+      t.Errorf("%s: topology lost", name)
+    }
+    // the fork was not advanced along with u
+    for tick := 0; tick < 5; tick++ {
+      fork.Advance()
+    }
+    if !fork.Grid().Equal(u.Grid()) {
+      t.Errorf("%s: fork diverged", name)
+    }
+  }
+}
+
+func TestRestoreNeedsRNG(t *testing.T) {
+  u := NewNoisyUniverse(4, 4, 0.5, 50, substrates.NewSplitMix64(1))
+  snap := u.Snapshot()
+  snap.RNG = nil
+  if _, err := Restore(snap); err == nil {
+    t.Errorf("restored a noisy universe without its rng")
+  }
+}
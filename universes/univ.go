@@ -1,4 +1,5 @@
 package universes
+import "fmt"
 import "oscarkilo.com/inteluni/substrates"
 
 type Universe interface {
@@ -6,4 +7,82 @@ type Universe interface {
   Advance()                           // step forward by one tick
   MakeEvolver() substrates.Evolver    // used to see possible futures
   Deterministic() bool                // is this universe deterministic
+  Snapshot() Snapshot                 // complete state, see Restore
+}
+
+// WithTopology sets how the edges of u's substrate connect and returns
+// u.  Call it before the first Advance; the topology then carries over
+// to every later grid and to the grids produced by u's Evolver.
+func WithTopology(u Universe, t substrates.Topology) Universe {
+  u.Grid().SetTopology(t)
+  return u
+}
+
+// Snapshot is the complete state of a universe at some tick, enough to
+// rebuild it exactly with Restore: its registry name and parameters,
+// its grid, and its random stream if it still draws from one.  It
+// encodes as JSON or gob, so long runs can be checkpointed.
+type Snapshot struct {
+  Universe string                 `json:"universe"`
+  Params   Params                 `json:"params"`
+  Grid     []byte                 `json:"grid"`  // Grid2d.MarshalBinary
+  RNG      *substrates.SplitMix64 `json:"rng,omitempty"`
+}
+
+// Restore rebuilds the universe a Snapshot was taken of.  The result
+// shares nothing with the original: it evolves exactly as the original
+// would have from the same tick, but advancing one leaves the other as
+// it is.
+func Restore(s Snapshot) (Universe, error) {
+  spec, err := Lookup(s.Universe)
+  if err != nil {
+    return nil, err
+  }
+  p, err := spec.Check(s.Params)
+  if err != nil {
+    return nil, err
+  }
+  g := &substrates.Grid2d{}
+  if err := g.UnmarshalBinary(s.Grid); err != nil {
+    return nil, fmt.Errorf("universe %q: %v", s.Universe, err)
+  }
+  var rng *substrates.SplitMix64
+  if s.RNG != nil {
+    rng = s.RNG.Clone()
+  }
+  return spec.Restore(g, p, rng)
+}
+
+// Fork returns an independent copy of u in its current state, e.g. to
+// run a second population of agents through the same future.
+func Fork(u Universe) Universe {
+  f, err := Restore(u.Snapshot())
+  if err != nil {
+    panic(err)
+  }
+  return f
+}
+
+// snapshot builds a Snapshot of a registered universe.
+func snapshot(
+    name string,
+    p Params,
+    g *substrates.Grid2d,
+    rng *substrates.SplitMix64,
+) Snapshot {
+  grid, err := g.MarshalBinary()
+  if err != nil {
+    panic(err)
+  }
+  s := Snapshot{Universe: name, Params: p, Grid: grid}
+  if rng != nil {
+    s.RNG = rng.Clone()
+  }
+  return s
+}
+
+// needRNG is the error for restoring a random universe without its
+// random stream.
+func needRNG(name string) error {
+  return fmt.Errorf("universe %q: snapshot has no rng state", name)
 }
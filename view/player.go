@@ -0,0 +1,152 @@
+package view
+import "fmt"
+import "io"
+import "strings"
+import "time"
+
+// Player animates frames on a terminal.
+//
+// Keys, read one byte at a time (see Cbreak):
+//   space   pause / resume
+//   n  l    step forward one tick (pauses)
+//   b  h    step back one tick (pauses)
+//   0       back to tick 0 (pauses)
+//   +  -    faster / slower
+//   q       quit
+type Player struct {
+  Out   io.Writer
+  Keys  io.Reader      // nil: no controls, play straight through
+  Delay time.Duration  // between ticks
+  Color bool
+}
+
+const (
+  minDelay = 10 * time.Millisecond
+  maxDelay = 5 * time.Second
+)
+
+const keyHelp = "[space] pause  [n/b] step  [0] restart  [+/-] speed  [q] quit"
+
+// Play shows frames as they arrive, at most one per Delay.  Frames
+// already shown are kept so that they can be stepped back to.  Without
+// Keys, Play returns after the last frame; with Keys, it waits at the
+// end until q is pressed.
+func (p *Player) Play(frames <-chan Frame) error {
+  var keys <-chan byte
+  if p.Keys != nil {
+    keys = readKeys(p.Keys)
+  }
+  var history []Frame
+  pos := -1      // index into history of the frame on screen
+  ready := true  // the delay since the last advance has passed
+  paused := false
+  delay := p.Delay
+  var tick <-chan time.Time
+  show := func() error {
+    f := history[pos]
+    state := "playing"
+    switch {
+      case frames == nil && pos == len(history)-1:
+        state = "end"
+      case paused:
+        state = "paused"
+    }
+    var b strings.Builder
+    b.WriteString(ansiClear)
+    b.WriteString(Render(f, p.Color))
+    fmt.Fprintf(&b, "%s  [%s, %v/tick]\n", Status(f), state, delay)
+    if keys != nil {
+      b.WriteString(keyHelp + "\n")
+    }
+    _, err := io.WriteString(p.Out, b.String())
+    return err
+  }
+  for {
+    if !paused && ready {
+      if pos+1 < len(history) {
+        pos++
+        if err := show(); err != nil {
+          return err
+        }
+        ready = false
+        tick = time.After(delay)
+        continue
+      }
+      if frames == nil {
+        if keys == nil || len(history) == 0 {
+          return nil
+        }
+        paused = true
+        if err := show(); err != nil {
+          return err
+        }
+      }
+    }
+    select {
+      case f, ok := <-frames:
+        if !ok {
+          frames = nil
+          continue
+        }
+        history = append(history, f)
+      case <-tick:
+        tick = nil
+        ready = true
+      case k, ok := <-keys:
+        if !ok {
+          // no more input: play on to the end
+          keys = nil
+          paused = false
+          continue
+        }
+        switch k {
+          case 'q':
+            return nil
+          case ' ':
+            paused = !paused
+          case 'n', 'l':
+            paused = true
+            if pos+1 < len(history) {
+              pos++
+            }
+          case 'b', 'h':
+            paused = true
+            if pos > 0 {
+              pos--
+            }
+          case '0':
+            paused = true
+            pos = min(pos, 0)
+          case '+', '=':
+            delay = max(delay/2, minDelay)
+          case '-', '_':
+            delay = min(delay*2, maxDelay)
+          default:
+            continue
+        }
+        if pos >= 0 {
+          if err := show(); err != nil {
+            return err
+          }
+        }
+    }
+  }
+}
+
+func readKeys(r io.Reader) <-chan byte {
+  keys := make(chan byte)
+  go func() {
+    defer close(keys)
+    buf := make([]byte, 1)
+    for {
+      n, err := r.Read(buf)
+      if n == 1 {
+        keys <- buf[0]
+      }
+      if err != nil {
+        return
+      }
+    }
+  }()
+  return keys
+}
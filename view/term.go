@@ -0,0 +1,31 @@
+package view
+import "os"
+import "os/exec"
+import "strings"
+
+// Cbreak puts the terminal on stdin into cbreak mode, so that keys
+// reach Player.Keys one at a time and are not echoed, and hides the
+// cursor.  It returns a function restoring the previous state.  It
+// uses stty(1) rather than terminal ioctls to stay portable without
+// dependencies.
+func Cbreak() (restore func(), err error) {
+  saved, err := stty("-g")
+  if err != nil {
+    return nil, err
+  }
+  if _, err := stty("cbreak", "-echo"); err != nil {
+    return nil, err
+  }
+  os.Stdout.WriteString("\x1b[?25l")
+  return func() {
+    stty(strings.TrimSpace(saved))
+    os.Stdout.WriteString("\x1b[?25h")
+  }, nil
+}
+
+func stty(args ...string) (string, error) {
+  cmd := exec.Command("stty", args...)
+  cmd.Stdin = os.Stdin
+  out, err := cmd.Output()
+  return string(out), err
+}
@@ -0,0 +1,244 @@
+// Package view draws simulations on an ANSI terminal.
+//
+// A Frame is one tick of the world with every agent overlaid on it.
+// Frames come from a recorded sim.Episode (EpisodeFrames) or live from
+// a running simulation (Live), and a Player animates them.
+package view
+import "fmt"
+import "strings"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/agents"
+import "oscarkilo.com/inteluni/sim"
+
+// Frame is the world after tick T, with the agents that stood in it.
+type Frame struct {
+  T      int
+  Grid   *substrates.Grid2d
+  Agents []Mark
+}
+
+// Mark places one agent.  Dead marks the tick the agent died; it is
+// not drawn afterwards.
+type Mark struct {
+  ID   int
+  Kind string  // agents.KindReactive, ...
+  Pos  substrates.Pos
+  Dead bool
+}
+
+// Glyphs for agents by kind, and for an agent at the tick it died.
+const (
+  glyphDead     = 'x'
+  glyphStacked  = '*'
+)
+
+var kindGlyphs = map[string]rune{
+  agents.KindReactive:   'R',
+  agents.KindPredictive: 'P',
+  agents.KindMCTS:       'M',
+  agents.KindLearning:   'L',
+  agents.KindModel:      'W',
+}
+
+// ANSI escapes.  Cells are drawn two columns wide so the grid looks
+// square in most terminal fonts.
+const (
+  ansiReset   = "\x1b[0m"
+  ansiClear   = "\x1b[H\x1b[2J"
+  ansiLive    = "\x1b[48;5;250m"
+  ansiDecay   = "\x1b[48;5;240m"
+  ansiEmpty   = "\x1b[48;5;234m"
+  ansiDeadFg  = "\x1b[1;31m"
+)
+
+var kindColors = map[string]string{
+  agents.KindReactive:   "\x1b[1;33m",
+  agents.KindPredictive: "\x1b[1;36m",
+  agents.KindMCTS:       "\x1b[1;35m",
+  agents.KindLearning:   "\x1b[1;32m",
+  agents.KindModel:      "\x1b[1;34m",
+}
+
+// Render draws f.  With color off it uses the characters of
+// Grid2d.OntoStdout: _ empty, # live, + refractory.
+func Render(f Frame, color bool) string {
+  g := f.Grid
+  at := map[substrates.Pos][]Mark{}
+  for _, m := range f.Agents {
+    at[m.Pos] = append(at[m.Pos], m)
+  }
+  var b strings.Builder
+  for y := 0; y < g.H(); y++ {
+    for x := 0; x < g.W(); x++ {
+      val := g.XY(x, y)
+      marks := at[substrates.Pos{X: x, Y: y}]
+      if !color {
+        b.WriteRune(cellRune(val, marks))
+        continue
+      }
+      switch {
+        case val == substrates.Empty:
+          b.WriteString(ansiEmpty)
+        case val == substrates.Live:
+          b.WriteString(ansiLive)
+        default:
+          b.WriteString(ansiDecay)
+      }
+      if len(marks) == 0 {
+        b.WriteString("  ")
+        continue
+      }
+      fg := kindColors[marks[0].Kind]
+      if marks[0].Dead {
+        fg = ansiDeadFg
+      }
+      b.WriteString(fg)
+      b.WriteRune(markRune(marks))
+      b.WriteRune(' ')
+      b.WriteString(ansiReset)
+    }
+    if color {
+      b.WriteString(ansiReset)
+    }
+    b.WriteByte('\n')
+  }
+  return b.String()
+}
+
+func cellRune(val int, marks []Mark) rune {
+  if len(marks) > 0 {
+    return markRune(marks)
+  }
+  switch val {
+    case substrates.Empty:
+      return '_'
+    case substrates.Live:
+      return '#'
+    default:
+      return '+'
+  }
+}
+
+// markRune picks the glyph for the agents on one cell.
+func markRune(marks []Mark) rune {
+  if len(marks) > 1 {
+    return glyphStacked
+  }
+  if marks[0].Dead {
+    return glyphDead
+  }
+  if r, ok := kindGlyphs[marks[0].Kind]; ok {
+    return r
+  }
+  return '?'
+}
+
+// Status summarises f in one line: the tick and live agents by kind.
+func Status(f Frame) string {
+  alive := map[string]int{}
+  died := 0
+  for _, m := range f.Agents {
+    if m.Dead {
+      died++
+    } else {
+      alive[m.Kind]++
+    }
+  }
+  s := fmt.Sprintf("tick %d  %c %d  %c %d  %c %d",
+      f.T,
+      kindGlyphs[agents.KindReactive], alive[agents.KindReactive],
+      kindGlyphs[agents.KindPredictive], alive[agents.KindPredictive],
+      kindGlyphs[agents.KindMCTS], alive[agents.KindMCTS])
+  for _, kind := range []string{agents.KindLearning, agents.KindModel} {
+    if n := alive[kind]; n > 0 {
+      s += fmt.Sprintf("  %c %d", kindGlyphs[kind], n)
+    }
+  }
+  if died > 0 {
+    s += fmt.Sprintf("  %c %d", glyphDead, died)
+  }
+  return s
+}
+
+// EpisodeFrames turns a recorded episode into frames.
+func EpisodeFrames(ep *sim.Episode) []Frame {
+  frames := make([]Frame, ep.Ticks()+1)
+  for t := range frames {
+    frames[t] = Frame{T: t, Grid: ep.Frame(t)}
+    for _, at := range ep.Agents {
+      if t >= len(at.Path) {
+        continue  // died earlier
+      }
+      frames[t].Agents = append(frames[t].Agents, Mark{
+        ID:   at.ID,
+        Kind: at.Kind,
+        Pos:  at.Path[t],
+        Dead: at.DeathTick != 0 && at.DeathTick == t,
+      })
+    }
+  }
+  return frames
+}
+
+// Live runs r of e in the background, sending each tick's frame as it
+// happens.  The channel is closed when the run ends.  The simulation
+// waits for every frame to be received, so it runs no faster than the
+// player; if the receiver stops early it waits forever, which is fine
+// for a command that is about to exit.
+func Live(e *sim.Experiment, r sim.Run) <-chan Frame {
+  frames := make(chan Frame)
+  spawned := func(g *substrates.Grid2d, pop []agents.Agent) {
+    frames <- StartFrame(g, pop)
+  }
+  go func() {
+    defer close(frames)
+    e.Observe(r, spawned, Observer(frames))
+  }()
+  return frames
+}
+
+// StartFrame is the frame before the first tick, for callers of
+// sim.Simulate that feed a Player through Observer.
+func StartFrame(g *substrates.Grid2d, pop []agents.Agent) Frame {
+  return Frame{T: 0, Grid: g.Clone(), Agents: marks(pop, nil)}
+}
+
+// Observer returns a sim.Options.Observer that sends every tick's frame
+// on frames.
+func Observer(frames chan<- Frame) func(sim.Tick) {
+  return func(t sim.Tick) {
+    frames <- Frame{
+      T:      t.T,
+      Grid:   t.Grid.Clone(),
+      Agents: marks(t.Agents, t.Dead),
+    }
+  }
+}
+
+// Frames sends the given frames on a closed-when-done channel, for
+// playing recorded frames.
+func Frames(fs []Frame) <-chan Frame {
+  ch := make(chan Frame, len(fs))
+  for _, f := range fs {
+    ch <- f
+  }
+  close(ch)
+  return ch
+}
+
+func marks(pop, dead []agents.Agent) []Mark {
+  isDead := map[int]bool{}
+  for _, ag := range dead {
+    isDead[ag.ID()] = true
+  }
+  ms := make([]Mark, len(pop))
+  for i, ag := range pop {
+    ms[i] = Mark{
+      ID:   ag.ID(),
+      Kind: agents.Kind(ag),
+      Pos:  ag.Pos(),
+      Dead: isDead[ag.ID()],
+    }
+  }
+  return ms
+}
@@ -0,0 +1,162 @@
+package view
+import "io"
+import "strings"
+import "testing"
+import "time"
+import "oscarkilo.com/inteluni/substrates"
+import "oscarkilo.com/inteluni/agents"
+import "oscarkilo.com/inteluni/sim"
+
+func testFrame(t int) Frame {
+  g := substrates.NewGrid2d(4, 2)
+  g.SetXY(0, 0, substrates.Live)
+  g.SetXY(3, 1, 2)
+  return Frame{T: t, Grid: g, Agents: []Mark{
+    {ID: 1, Kind: agents.KindReactive, Pos: substrates.Pos{X: 1, Y: 0}},
+    {ID: 2, Kind: agents.KindPredictive, Pos: substrates.Pos{X: 2, Y: 1}},
+    {ID: 3, Kind: agents.KindMCTS, Pos: substrates.Pos{X: 3, Y: 0}},
+    {ID: 4, Kind: agents.KindMCTS, Pos: substrates.Pos{X: 0, Y: 1}},
+    {ID: 5, Kind: agents.KindReactive, Pos: substrates.Pos{X: 0, Y: 1}},
+    {ID: 6, Kind: agents.KindPredictive, Pos: substrates.Pos{X: 0, Y: 0},
+        Dead: true},
+  }}
+}
+
+func TestRenderPlain(t *testing.T) {
+  got := Render(testFrame(0), false)
+  want := "xR_M\n*_P+\n"
+  if got != want {
+    t.Errorf("got\n%swant\n%s", got, want)
+  }
+  status := Status(testFrame(7))
+  if status != "tick 7  R 2  P 1  M 2  x 1" {
+    t.Errorf("status %q", status)
+  }
+}
+
+func TestRenderColorKeepsLayout(t *testing.T) {
+  got := Render(testFrame(0), true)
+  if strings.Count(got, "\n") != 2 {
+    t.Errorf("want 2 lines, got %q", got)
+  }
+  for _, glyph := range []string{"R ", "P ", "M ", "x ", "* "} {
+    if !strings.Contains(got, glyph) {
+      t.Errorf("missing %q in %q", glyph, got)
+    }
+  }
+}
+
+func TestEpisodeFrames(t *testing.T) {
+  ep, err := sim.LoadEpisode("../sim/testdata/gameofnoise.episode.json")
+  if err != nil {
+    t.Fatal(err)
+  }
+  frames := EpisodeFrames(ep)
+  if len(frames) != ep.Ticks()+1 {
+    t.Fatalf("%d frames for %d ticks", len(frames), ep.Ticks())
+  }
+  for _, at := range ep.Agents {
+    for tick, f := range frames {
+      var mark *Mark
+      for i := range f.Agents {
+        if f.Agents[i].ID == at.ID {
+          mark = &f.Agents[i]
+        }
+      }
+      alive := at.DeathTick == 0 || tick < at.DeathTick
+      switch {
+        case alive && (mark == nil || mark.Dead || mark.Pos != at.Path[tick]):
+          t.Errorf("tick %d: agent %d mark %+v", tick, at.ID, mark)
+        case !alive && tick == at.DeathTick && (mark == nil || !mark.Dead):
+          t.Errorf("tick %d: agent %d death not marked", tick, at.ID)
+        case at.DeathTick != 0 && tick > at.DeathTick && mark != nil:
+          t.Errorf("tick %d: agent %d drawn after death", tick, at.ID)
+      }
+    }
+  }
+}
+
+func TestLiveMatchesEpisode(t *testing.T) {
+  ep, err := sim.LoadEpisode("../sim/testdata/gameofnoise.episode.json")
+  if err != nil {
+    t.Fatal(err)
+  }
+  want := EpisodeFrames(ep)
+  n := 0
+  for f := range Live(ep.Experiment, ep.Run) {
+    if n >= len(want) {
+      t.Fatalf("live run has more than %d frames", len(want))
+    }
+    if Render(f, false) != Render(want[n], false) || f.T != want[n].T {
+      t.Errorf("tick %d: live\n%srecorded\n%s",
+          n, Render(f, false), Render(want[n], false))
+    }
+    n++
+  }
+  if n != len(want) {
+    t.Errorf("live run has %d frames, want %d", n, len(want))
+  }
+}
+
+func TestPlayerWithoutKeys(t *testing.T) {
+  var out strings.Builder
+  p := &Player{Out: &out}
+  frames := []Frame{testFrame(0), testFrame(1), testFrame(2)}
+  if err := p.Play(Frames(frames)); err != nil {
+    t.Fatal(err)
+  }
+  screens := strings.Split(out.String(), ansiClear)[1:]
+  if len(screens) != 3 {
+    t.Fatalf("drew %d screens, want 3", len(screens))
+  }
+  if !strings.Contains(screens[2], "tick 2") {
+    t.Errorf("last screen %q", screens[2])
+  }
+}
+
+// screens passes every screen the player draws to a channel.
+type screens chan string
+
+func (s screens) Write(p []byte) (int, error) {
+  s <- string(p)
+  return len(p), nil
+}
+
+func TestPlayerControls(t *testing.T) {
+  out := make(screens)
+  keys, press := io.Pipe()
+  p := &Player{Out: out, Keys: keys, Delay: time.Hour}
+  frames := []Frame{testFrame(0), testFrame(1), testFrame(2)}
+  done := make(chan error)
+  go func() {
+    done <- p.Play(Frames(frames))
+  }()
+  expect := func(want string) {
+    t.Helper()
+    select {
+      case screen := <-out:
+        if !strings.Contains(screen, want) {
+          t.Errorf("want %q on screen:\n%s", want, screen)
+        }
+      case <-time.After(5 * time.Second):
+        t.Fatalf("timed out waiting for %q", want)
+    }
+  }
+  expect("tick 0  ")
+  for _, step := range []struct{ key, want string }{
+    {"n", "tick 1  "},
+    {"n", "tick 2  "},
+    {"n", "[end"},
+    {"b", "tick 1  "},
+    {"0", "tick 0  "},
+    {"+", "30m0s/tick"},
+    {"-", "5s/tick"},
+  } {
+    press.Write([]byte(step.key))
+    expect(step.want)
+  }
+  press.Write([]byte("q"))
+  if err := <-done; err != nil {
+    t.Fatal(err)
+  }
+}